@@ -0,0 +1,10 @@
+package main
+
+// Backends register themselves to apmapi in init(), private backends can be compiled in by adding a blank import in another file of this package.
+import (
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/elastic"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/pinpoint"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+)
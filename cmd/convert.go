@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	formatNodes = "nodes"
+	formatSpans = "spans"
+	formatOtlp  = "otlp"
+
+	// validateFile is the expected output stored next to data.json in testdata, it is skipped when converting a directory.
+	validateFile = "validate.json"
+)
+
+type convertResult struct {
+	File          string                   `json:"file,omitempty"`
+	TraceId       string                   `json:"traceId"`
+	Services      []*model.OtelServiceNode `json:"services,omitempty"`
+	Spans         []*model.OtelSpan        `json:"spans,omitempty"`
+	ResourceSpans []*remote.ResourceSpan   `json:"resourceSpans,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+}
+
+// convert runs the backend conversion on saved raw responses, no backend is connected.
+func convert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("from", "", fmt.Sprintf("Backend of the dump, one of %v", apmapi.ListBackends()))
+	in := flags.String("in", "", "Raw upstream response, the data.json shape in testdata, or a directory of them")
+	out := flags.String("out", "", "Output file, or directory when -in is a directory; stdout if empty")
+	format := flags.String("format", formatNodes, "Output format: nodes, spans or otlp")
+	flags.Parse(args)
+
+	backend, exist := apmapi.GetBackend(*from)
+	if !exist || backend.ConvertFixture == nil {
+		return fmt.Errorf("unknown backend %q for -from, want one of %v", *from, apmapi.ListBackends())
+	}
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	if *format != formatNodes && *format != formatSpans && *format != formatOtlp {
+		return fmt.Errorf("unknown format %q, want nodes, spans or otlp", *format)
+	}
+
+	info, err := os.Stat(*in)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		result, err := convertFile(backend, *in, *format)
+		if err != nil {
+			return err
+		}
+		return writeJson(*out, result)
+	}
+	return convertDir(backend, *in, *out, *format)
+}
+
+// convertDir converts every json file under dir, a failed dump is reported and the others are still converted.
+func convertDir(backend *apmapi.Backend, dir string, out string, format string) error {
+	results := make([]*convertResult, 0)
+	failed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" || entry.Name() == validateFile {
+			return nil
+		}
+		relPath, _ := filepath.Rel(dir, path)
+		result, err := convertFile(backend, path, format)
+		if err != nil {
+			failed++
+			result = &convertResult{Error: err.Error()}
+		}
+		result.File = relPath
+		if out == "" {
+			results = append(results, result)
+			return nil
+		}
+		return writeJson(filepath.Join(out, relPath), result)
+	})
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		if err = writeJson("", results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d dumps under %s failed to convert", failed, dir)
+	}
+	return nil
+}
+
+func convertFile(backend *apmapi.Backend, path string, format string) (*convertResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	traceId, services, err := backend.ConvertFixture(data)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s: %w", path, err)
+	}
+
+	result := &convertResult{TraceId: traceId}
+	switch format {
+	case formatSpans:
+		result.Spans = flattenSpans(services)
+	case formatOtlp:
+		result.ResourceSpans = remote.ToResourceSpans(traceId, flattenSpans(services))
+	default:
+		result.Services = services
+	}
+	return result, nil
+}
+
+// flattenSpans collects the entry and exit spans of the service tree ordered by startTime.
+func flattenSpans(services []*model.OtelServiceNode) []*model.OtelSpan {
+	spans := make([]*model.OtelSpan, 0)
+	visited := make(map[*model.OtelSpan]bool)
+	collect := func(nodeSpans []*model.OtelSpan) {
+		for _, span := range nodeSpans {
+			if !visited[span] {
+				visited[span] = true
+				spans = append(spans, span)
+			}
+		}
+	}
+	var walk func(nodes []*model.OtelServiceNode)
+	walk = func(nodes []*model.OtelServiceNode) {
+		for _, node := range nodes {
+			collect(node.EntrySpans)
+			collect(node.ExitSpans)
+			walk(node.Children)
+		}
+	}
+	walk(services)
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].StartTime < spans[j].StartTime
+	})
+	return spans
+}
+
+func writeJson(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
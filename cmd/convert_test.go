@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+)
+
+func readConvertResult(t *testing.T, path string) *convertResult {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &convertResult{}
+	if err = json.Unmarshal(data, result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestConvert(t *testing.T) {
+	caseDir := testdataDir + "jaeger/http"
+	expect, err := golden.ReadTraceCase(filepath.Join(caseDir, golden.ValidateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	out := filepath.Join(dir, "nodes.json")
+	if err = convert([]string{"-from", "jaeger", "-in", filepath.Join(caseDir, golden.DataFile), "-out", out}); err != nil {
+		t.Fatal(err)
+	}
+	result := readConvertResult(t, out)
+	if result.TraceId != expect.TraceId {
+		t.Errorf("[Check traceId] want=%s, got=%s", expect.TraceId, result.TraceId)
+	}
+	for _, diff := range golden.DiffServices(expect.Services, result.Services) {
+		t.Errorf("[Check nodes] %s", diff)
+	}
+
+	out = filepath.Join(dir, "spans.json")
+	if err = convert([]string{"-from", "jaeger", "-in", filepath.Join(caseDir, golden.DataFile), "-out", out, "-format", "spans"}); err != nil {
+		t.Fatal(err)
+	}
+	result = readConvertResult(t, out)
+	if expectSpans := flattenSpans(expect.Services); len(result.Spans) != len(expectSpans) {
+		t.Errorf("[Check spans] want=%d, got=%d", len(expectSpans), len(result.Spans))
+	}
+	for i := 1; i < len(result.Spans); i++ {
+		if result.Spans[i].StartTime < result.Spans[i-1].StartTime {
+			t.Errorf("[Check spans] want ordered by startTime, got %s before %s", result.Spans[i-1].SpanId, result.Spans[i].SpanId)
+		}
+	}
+
+	// validate.json is skipped, every data.json is converted to the same relative path under -out.
+	outDir := filepath.Join(dir, "jaeger")
+	if err = convert([]string{"-from", "jaeger", "-in", testdataDir + "jaeger", "-out", outDir}); err != nil {
+		t.Fatal(err)
+	}
+	result = readConvertResult(t, filepath.Join(outDir, "http", golden.DataFile))
+	if result.File != filepath.Join("http", golden.DataFile) {
+		t.Errorf("[Check dir] want file=%s, got=%s", filepath.Join("http", golden.DataFile), result.File)
+	}
+	for _, diff := range golden.DiffServices(expect.Services, result.Services) {
+		t.Errorf("[Check dir] %s", diff)
+	}
+	if _, err = os.Stat(filepath.Join(outDir, "http", golden.ValidateFile)); !os.IsNotExist(err) {
+		t.Errorf("[Check dir] want validate.json skipped, got err=%v", err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	dir := t.TempDir()
+	brokenDir := filepath.Join(dir, "dumps")
+	if err := os.MkdirAll(brokenDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(brokenDir, "broken.json"), []byte(`{"data":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		args   []string
+		expect string
+	}{
+		{"unknownBackend", []string{"-from", "zipkin", "-in", brokenDir}, `unknown backend "zipkin"`},
+		{"noInput", []string{"-from", "jaeger"}, "-in is required"},
+		{"unknownFormat", []string{"-from", "jaeger", "-in", brokenDir, "-format", "csv"}, `unknown format "csv"`},
+		{"brokenFile", []string{"-from", "jaeger", "-in", filepath.Join(brokenDir, "broken.json")}, "broken.json"},
+		{"brokenDir", []string{"-from", "jaeger", "-in", brokenDir, "-out", filepath.Join(dir, "out")}, "1 dumps under"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := convert(testCase.args)
+			if err == nil || !strings.Contains(err.Error(), testCase.expect) {
+				t.Errorf("[Check error] want=%s, got=%v", testCase.expect, err)
+			}
+		})
+	}
+	// The failed dump is still reported in the output of the directory.
+	result := readConvertResult(t, filepath.Join(dir, "out", "broken.json"))
+	if result.Error == "" {
+		t.Errorf("[Check brokenDir] want the error of broken.json reported, got none")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -14,25 +15,48 @@ import (
 	"github.com/spf13/viper"
 )
 
+// commands are the subcommands besides the default http server.
+var commands = map[string]func(args []string) error{
+	"validate-config": validateConfig,
+	"convert":         convert,
+	"query":           query,
+}
+
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatalf("Failed to run application: %v", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	configPath := flag.String("config", "apm-adapter.yml", "Configuration file")
-	flag.Parse()
+func run(args []string) error {
+	if len(args) > 0 {
+		if command, exist := commands[args[0]]; exist {
+			return command(args[1:])
+		}
+	}
+	return runServer(args)
+}
+
+func runServer(args []string) error {
+	flags := flag.NewFlagSet("apo-apm-adapter", flag.ExitOnError)
+	configPath := flags.String("config", "apm-adapter.yml", "Configuration file")
+	recordDir := flags.String("record-dir", "", "Enable the X-Apo-Record header, the recorded fixtures are saved under this directory")
+	flags.Parse(args)
+	global.RECORD_DIR = *recordDir
 	adapterCfg, err := readInConfig(*configPath)
 	if err != nil {
 		return fmt.Errorf("fail to read configuration: %w", err)
 	}
+	log.Printf("[Config] %s", adapterCfg)
 	apmTraceClient, err := apmtrace.NewApmTraceClient(adapterCfg.TraceApi, adapterCfg.Timeout)
 	if err != nil {
 		return fmt.Errorf("fail to connect apm trace client: %w", err)
 	}
-	global.TRACE_CLIENT = apmTraceClient
+	global.TRACE_CLIENT.Store(apmTraceClient)
+
+	reloader := newConfigReloader(*configPath, adapterCfg)
+	reloader.watch()
 
 	httpserver.StartHttpServer(adapterCfg.HttpPort)
 	return nil
@@ -45,8 +69,23 @@ func readInConfig(path string) (*config.AdapterConfig, error) {
 	if err != nil { // Handle errors reading the config file
 		return nil, fmt.Errorf("error happened while reading config file: %w", err)
 	}
-	adapterCfg := &config.AdapterConfig{}
-	_ = viper.UnmarshalKey("adapter", adapterCfg)
+	if err = config.BindEnv(viper, "adapter"); err != nil {
+		return nil, fmt.Errorf("error happened while reading env: %w", err)
+	}
+	unknownKeyErr := config.CheckUnknownKeys(viper.AllSettings(), "adapter")
+	// Unmarshal from AllSettings, UnmarshalKey ignores the env overrides of nested keys.
+	wrapper := struct {
+		Adapter *config.AdapterConfig `mapstructure:"adapter"`
+	}{
+		Adapter: &config.AdapterConfig{},
+	}
+	if err = viper.Unmarshal(&wrapper); err != nil {
+		return nil, fmt.Errorf("error happened while parsing config file: %w", err)
+	}
+	adapterCfg := wrapper.Adapter
+	if err = errors.Join(unknownKeyErr, adapterCfg.Validate()); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
 
 	return adapterCfg, nil
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// query looks up a trace with the same ApmTraceClient as the server, it is used to debug the conversion without curl.
+func query(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := flags.String("config", "apm-adapter.yml", "Configuration file")
+	apm := flags.String("apm", "", "Backend in apm_list or the apmType requested by APO, eg. skywalking")
+	traceId := flags.String("trace", "", "TraceId to query")
+	startTime := flags.Int64("start", 0, "Start time of the trace in ms, required by some backends to narrow the search")
+	attributes := flags.String("attributes", "", "Attributes passed to the backend as in /trace/list")
+	asJson := flags.Bool("json", false, "Print the service nodes as json instead of the tree")
+	recordDir := flags.String("record-dir", "", "Save the raw response as a fixture under <record-dir>/<backend>/<case>, eg. pkg/apmtrace/apmapi/testdata/tracelist")
+	recordCase := flags.String("case", "", "Case name of the recorded fixture")
+	anonymize := flags.Bool("anonymize", false, "Replace service names and IPs in the recorded fixture")
+	overwrite := flags.Bool("overwrite", false, "Replace the recorded fixture if the case exists")
+	strict := flags.Bool("strict", false, "Fail on an incomplete trace instead of printing the received spans, overrides the strict config of the backend")
+	flags.Parse(args)
+
+	if *apm == "" || *traceId == "" {
+		return errors.New("-apm and -trace are required")
+	}
+	adapterCfg, err := readInConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	client, err := apmtrace.NewApmTraceClient(adapterCfg.TraceApi, adapterCfg.Timeout)
+	if err != nil {
+		return fmt.Errorf("fail to connect apm trace client: %w", err)
+	}
+
+	apmType := *apm
+	if backend, exist := apmapi.GetBackend(*apm); exist {
+		apmType = backend.ApmType
+	}
+	opts := apmapi.QueryOptions{}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "strict" {
+			opts.Strict = strict
+		}
+	})
+	var (
+		services []*model.OtelServiceNode
+		complete bool
+	)
+	if *recordDir != "" {
+		services, complete, err = client.RecordTraceList(apmType, *traceId, *startTime, *attributes, opts, &apmtrace.RecordOptions{
+			Dir:       *recordDir,
+			Case:      *recordCase,
+			Anonymize: *anonymize,
+			Overwrite: *overwrite,
+		})
+	} else {
+		services, complete, err = client.QueryPartialTraceList(apmType, *traceId, *startTime, *attributes, opts)
+	}
+	if err != nil {
+		return err
+	}
+	if !complete {
+		fmt.Fprintf(os.Stderr, "trace %s is incomplete, the subtrees of the missing spans are printed as other roots\n", *traceId)
+	}
+	if *asJson {
+		return writeJson("", &convertResult{TraceId: *traceId, Services: services})
+	}
+	printServiceTree(os.Stdout, services)
+	return nil
+}
+
+// printServiceTree prints one service per line, followed by its entry, exit and error spans, children are indented.
+func printServiceTree(w io.Writer, services []*model.OtelServiceNode) {
+	var print func(nodes []*model.OtelServiceNode, depth int)
+	print = func(nodes []*model.OtelServiceNode, depth int) {
+		indent := strings.Repeat("    ", depth)
+		for _, node := range nodes {
+			fmt.Fprintf(w, "%s%s\n", indent, getNodeServiceName(node))
+			printSpans(w, indent, "entry", node.EntrySpans)
+			printSpans(w, indent, "exit", node.ExitSpans)
+			printSpans(w, indent, "error", node.ErrorSpans)
+			print(node.Children, depth+1)
+		}
+	}
+	print(services, 0)
+}
+
+func printSpans(w io.Writer, indent string, spanType string, spans []*model.OtelSpan) {
+	for _, span := range spans {
+		fmt.Fprintf(w, "%s  - %-5s %s [%s] %s %s\n", indent, spanType, span.Name,
+			span.Kind.String(), time.Duration(span.Duration), span.Code.String())
+	}
+}
+
+// getNodeServiceName reads the name from spans, ServiceName of the node is not exported to json and may be empty.
+func getNodeServiceName(node *model.OtelServiceNode) string {
+	if node.ServiceName != "" {
+		return node.ServiceName
+	}
+	for _, span := range node.EntrySpans {
+		if span.ServiceName != "" {
+			return span.ServiceName
+		}
+	}
+	return "<unknown>"
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+)
+
+// mainArgsEnv makes the test binary run main with the arguments separated by newlines, it is set by runMainProcess.
+const mainArgsEnv = "APO_ADAPTER_TEST_MAIN_ARGS"
+
+const expectErrorTree = `stuck-tomcat
+  - entry GET /wait/callOthers [Server] 26.446ms Error
+  - exit  GET [Client] 12.937ms Error
+  - error WaitController.callOther [Unspecified] 20.624ms Error
+  - error GET [Client] 12.937ms Error
+    stuck-undertow
+      - entry GET /wait/fail [Server] 10.714ms Error
+      - error WaitController.fail [Unspecified] 3.46ms Error
+`
+
+// captureStdout returns what run prints to stdout.
+func captureStdout(t *testing.T, run func() error) (string, error) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+	err = run()
+	os.Stdout = stdout
+	writer.Close()
+	return <-output, err
+}
+
+// runMainProcess runs main in a child process of the test binary, and returns its exit code and stderr.
+func runMainProcess(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestQueryExitCode$")
+	cmd.Env = append(os.Environ(), mainArgsEnv+"="+strings.Join(args, "\n"))
+	stderr := &strings.Builder{}
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), stderr.String()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0, stderr.String()
+}
+
+func newQueryServer(t *testing.T, caseName string) (*apmtest.FakeServer, string, string) {
+	t.Helper()
+	server := apmtest.NewJaegerServer()
+	t.Cleanup(server.Close)
+	traceId, err := server.AddFixture(testdataDir + "jaeger/" + caseName + "/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeJaegerConfig(t, filepath.Join(t.TempDir(), "apm-adapter.yml"), server.Address())
+	return server, traceId, path
+}
+
+func TestQuery(t *testing.T) {
+	_, traceId, path := newQueryServer(t, "error")
+
+	output, err := captureStdout(t, func() error {
+		return query([]string{"-config", path, "-apm", "jaeger", "-trace", traceId})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != expectErrorTree {
+		t.Errorf("[Check tree] want=\n%s\ngot=\n%s", expectErrorTree, output)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return query([]string{"-config", path, "-apm", "jaeger", "-trace", traceId, "-json"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := golden.ReadTraceCase(testdataDir + "jaeger/error/" + golden.ValidateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &convertResult{}
+	if err = json.Unmarshal([]byte(output), result); err != nil {
+		t.Fatalf("[Check json] fail to decode the output: %v", err)
+	}
+	if result.TraceId != traceId {
+		t.Errorf("[Check json] want traceId=%s, got=%s", traceId, result.TraceId)
+	}
+	for _, diff := range golden.DiffServices(expect.Services, result.Services) {
+		t.Errorf("[Check json] %s", diff)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	server, traceId, path := newQueryServer(t, "http")
+
+	err := query([]string{"-config", path, "-apm", "jaeger"})
+	if err == nil || !strings.Contains(err.Error(), "-apm and -trace are required") {
+		t.Errorf("[Check missing trace] got=%v", err)
+	}
+	err = query([]string{"-config", filepath.Join(t.TempDir(), "missing.yml"), "-apm", "jaeger", "-trace", traceId})
+	if err == nil {
+		t.Errorf("[Check missing config] want error, got nil")
+	}
+	err = query([]string{"-config", path, "-apm", "jaeger", "-trace", "00000000000000000000000000000000"})
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeNotFound {
+		t.Errorf("[Check not found] want=%s, got=%s (%v)", apmapi.ErrCodeNotFound, got, err)
+	}
+	server.SetBasicAuth("admin", "secret")
+	err = query([]string{"-config", path, "-apm", "jaeger", "-trace", traceId})
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeUnauthorized {
+		t.Errorf("[Check unauthorized] want=%s, got=%s (%v)", apmapi.ErrCodeUnauthorized, got, err)
+	}
+}
+
+func TestQueryExitCode(t *testing.T) {
+	if args, exist := os.LookupEnv(mainArgsEnv); exist {
+		os.Args = append([]string{"apo-apm-adapter"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	_, traceId, path := newQueryServer(t, "http")
+
+	testCases := []struct {
+		name       string
+		args       []string
+		expectCode int
+		expectErr  string
+	}{
+		{"found", []string{"query", "-config", path, "-apm", "jaeger", "-trace", traceId}, 0, ""},
+		{"missingTrace", []string{"query", "-config", path, "-apm", "jaeger"}, 1, "-apm and -trace are required"},
+		{"notFound", []string{"query", "-config", path, "-apm", "jaeger", "-trace", "00000000000000000000000000000000"}, 1, "Failed to run application"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			code, stderr := runMainProcess(t, testCase.args...)
+			if code != testCase.expectCode {
+				t.Errorf("[Check exit code] want=%d, got=%d, stderr=%s", testCase.expectCode, code, stderr)
+			}
+			if !strings.Contains(stderr, testCase.expectErr) {
+				t.Errorf("[Check stderr] want=%s, got=%s", testCase.expectErr, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/global"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// configReloader rebuilds the ApmTraceClient when the config file changes or SIGHUP is received.
+type configReloader struct {
+	path string
+
+	lock    sync.Mutex
+	current *config.AdapterConfig
+}
+
+func newConfigReloader(path string, current *config.AdapterConfig) *configReloader {
+	return &configReloader{
+		path:    path,
+		current: current,
+	}
+}
+
+func (r *configReloader) watch() {
+	watcher := viper.New()
+	watcher.SetConfigFile(r.path)
+	watcher.OnConfigChange(func(in fsnotify.Event) {
+		log.Printf("[Reload] config file %s is changed", in.Name)
+		r.reload()
+	})
+	watcher.WatchConfig()
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		for range c {
+			log.Printf("[Reload] SIGHUP is received")
+			r.reload()
+		}
+	}()
+}
+
+func (r *configReloader) reload() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	adapterCfg, err := readInConfig(r.path)
+	if err != nil {
+		log.Printf("[x Reload] Keep running config, %v", err)
+		return
+	}
+	if reflect.DeepEqual(adapterCfg, r.current) {
+		return
+	}
+	if adapterCfg.HttpPort != r.current.HttpPort {
+		log.Printf("[Reload] http_port change %d -> %d requires restart, ignored", r.current.HttpPort, adapterCfg.HttpPort)
+	}
+
+	apmTraceClient, err := apmtrace.NewApmTraceClient(adapterCfg.TraceApi, adapterCfg.Timeout)
+	if err != nil {
+		log.Printf("[x Reload] Keep running config, fail to connect apm trace client: %v", err)
+		return
+	}
+	global.TRACE_CLIENT.Store(apmTraceClient)
+	r.current = adapterCfg
+	log.Printf("[Reload] apm trace client is rebuilt")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/global"
+)
+
+const testdataDir = "../pkg/apmtrace/apmapi/testdata/tracelist/"
+
+// writeJaegerConfig writes a config which queries the jaeger at address and returns its path.
+func writeJaegerConfig(t *testing.T, path string, address string) string {
+	t.Helper()
+	content := fmt.Sprintf("adapter:\n  http_port: 8079\n  timeout: 5\n  trace_api:\n    apm_list: [jaeger]\n    jaeger:\n      address: %q\n", address)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startTraceClient stores the client of the config at path as the server does, the previous client is restored after the test.
+func startTraceClient(t *testing.T, path string) *configReloader {
+	t.Helper()
+	adapterCfg, err := readInConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := apmtrace.NewApmTraceClient(adapterCfg.TraceApi, adapterCfg.Timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := global.TRACE_CLIENT.Swap(client)
+	t.Cleanup(func() {
+		global.TRACE_CLIENT.Store(previous)
+	})
+	return newConfigReloader(path, adapterCfg)
+}
+
+func TestConfigReload(t *testing.T) {
+	oldServer := apmtest.NewJaegerServer()
+	defer oldServer.Close()
+	newServer := apmtest.NewJaegerServer()
+	defer newServer.Close()
+	traceId, err := newServer.AddFixture(testdataDir + "jaeger/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeJaegerConfig(t, filepath.Join(t.TempDir(), "apm-adapter.yml"), oldServer.Address())
+	reloader := startTraceClient(t, path)
+	oldClient := global.TRACE_CLIENT.Load()
+
+	reloader.reload()
+	if got := global.TRACE_CLIENT.Load(); got != oldClient {
+		t.Errorf("[Check unchanged] want the running client kept, got a rebuilt one")
+	}
+
+	if err = os.WriteFile(path, []byte("adapter:\n  http_port: 8079\n  timeout: 0\n  trace_api:\n    apm_list: [jaeger]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloader.reload()
+	if got := global.TRACE_CLIENT.Load(); got != oldClient {
+		t.Errorf("[Check invalid] want the running client kept, got a rebuilt one")
+	}
+	if got := reloader.current.Timeout; got != 5 {
+		t.Errorf("[Check invalid] want the running config kept, want timeout=5, got=%d", got)
+	}
+	if _, err = oldClient.QueryTraceList(jaeger.ApmType, traceId, 0, ""); err == nil {
+		t.Errorf("[Check invalid] want the old backend queried, got the trace of the new one")
+	}
+
+	writeJaegerConfig(t, path, newServer.Address())
+	reloader.reload()
+	newClient := global.TRACE_CLIENT.Load()
+	if newClient == oldClient {
+		t.Fatalf("[Check changed] want a rebuilt client, got the running one")
+	}
+	if _, err = newClient.QueryTraceList(jaeger.ApmType, traceId, 0, ""); err != nil {
+		t.Errorf("[Check changed] want the new backend queried, got=%v", err)
+	}
+	// The requests in flight keep the client they loaded.
+	if _, err = oldClient.QueryTraceList(jaeger.ApmType, traceId, 0, ""); err == nil {
+		t.Errorf("[Check old client] want the old backend queried, got the trace of the new one")
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// validateConfig checks the config file without connecting any backend, it is used by CI pipelines.
+func validateConfig(args []string) error {
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	configPath := flags.String("config", "apm-adapter.yml", "Configuration file")
+	flags.Parse(args)
+
+	adapterCfg, err := readInConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid, apm_list: %v\n", *configPath, adapterCfg.TraceApi.ApmList)
+	return nil
+}
@@ -7,3 +7,46 @@ import (
 type QueryByApmApi interface {
 	QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error)
 }
+
+// HealthCheckApi is implemented by backends which can actively probe whether the upstream is reachable and authorized.
+// All built-in backends implement it, a backend without it is reported as unknown and keeps the adapter from being ready.
+type HealthCheckApi interface {
+	CheckHealth() error
+}
+
+// RawQueryApi is implemented by backends which can fetch the raw upstream response, the data.json shape accepted by Backend.ConvertFixture.
+type RawQueryApi interface {
+	QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error)
+}
+
+// QueryOptions overrides the config of the backend for one query, nil fields keep the config.
+type QueryOptions struct {
+	// Strict returns the Incomplete error for an incomplete trace instead of the spans received so far.
+	Strict *bool
+	// ClockSkew adjusts the converted spans before the relations of the services are built, nil leaves them as they are.
+	ClockSkew *ClockSkewAdjuster
+}
+
+// OptionsQueryApi is implemented by backends which apply QueryOptions to the conversion, eg. the clock skew adjustment.
+type OptionsQueryApi interface {
+	QueryListWithOptions(traceId string, startTimeMs int64, attributes string, opts QueryOptions) ([]*model.OtelServiceNode, error)
+}
+
+// RawConvertApi is implemented by backends which convert a response of QueryRaw as their queries do, so a recorded query
+// replies with the configured mapping and options applied, unlike Backend.ConvertFixture.
+type RawConvertApi interface {
+	ConvertRaw(traceId string, data []byte, opts QueryOptions) (services []*model.OtelServiceNode, complete bool, err error)
+}
+
+// PartialQueryApi is implemented by backends which can convert an incomplete trace,
+// complete is false if the returned services miss the spans which are not reported yet or lost.
+type PartialQueryApi interface {
+	QueryPartialList(traceId string, startTimeMs int64, attributes string, opts QueryOptions) (services []*model.OtelServiceNode, complete bool, err error)
+}
+
+// AttributeOrphanParentSpanId is set to the root span of a subtree whose parent span is missing from an incomplete trace,
+// the subtree is returned as another root service.
+const AttributeOrphanParentSpanId = "apo.orphan.parent_span_id"
+
+// AttributeDBStatementParameters carries the bind values of db.statement, it is dropped with the literals when the sql is obfuscated.
+const AttributeDBStatementParameters = "db.statement.parameters"
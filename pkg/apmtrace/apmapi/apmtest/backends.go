@@ -0,0 +1,92 @@
+package apmtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// NewSkywalkingServer fakes the GraphQL endpoint of SkyWalking OAP, queryTrace replies an empty span list for unknown traces.
+func NewSkywalkingServer() *FakeServer {
+	return newFakeServer("skywalking", func(s *FakeServer, w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var request struct {
+			Query     string `json:"query"`
+			Variables struct {
+				TraceId string `json:"traceId"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(request.Query, "__schema") {
+			replyJson(w, `{"data":{"__schema":{"queryType":{"name":"Query"}}}}`)
+			return
+		}
+		s.replyTrace(w, request.Variables.TraceId, http.StatusOK, `{"data":{"trace":{"spans":[]}}}`)
+	})
+}
+
+// NewJaegerServer fakes the query service of Jaeger, unknown traces are replied with 404.
+func NewJaegerServer() *FakeServer {
+	return newFakeServer("jaeger", func(s *FakeServer, w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/api/services":
+			replyJson(w, `{"data":[],"total":0}`)
+		case strings.HasPrefix(r.URL.Path, "/api/traces/"):
+			traceId := strings.TrimPrefix(r.URL.Path, "/api/traces/")
+			s.replyTrace(w, traceId, http.StatusNotFound, `{"data":null,"errors":[{"code":404,"msg":"trace not found"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+// NewPinpointServer fakes the web api of Pinpoint, unknown traces are replied with an exception as pinpoint-web does.
+func NewPinpointServer() *FakeServer {
+	return newFakeServer("pinpoint", func(s *FakeServer, w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/serverTime.pinpoint":
+			replyJson(w, `{"currentServerTime":1718104634862}`)
+		case r.URL.Path == "/transactionInfo.pinpoint":
+			traceId := r.URL.Query().Get("traceId")
+			s.replyTrace(w, traceId, http.StatusOK, `{"exception":{"message":"transaction is not found","stacktrace":""}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+// NewElasticServer fakes the Elasticsearch _search and _cluster/health api, unknown traces are replied without hits.
+func NewElasticServer() *FakeServer {
+	return newFakeServer("elastic", func(s *FakeServer, w http.ResponseWriter, r *http.Request) {
+		// Checked by go-elasticsearch before the first request.
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		switch {
+		case r.URL.Path == "/":
+			replyJson(w, `{"cluster_name":"apmtest","version":{"number":"7.17.10","build_flavor":"default"},"tagline":"You Know, for Search"}`)
+		case r.URL.Path == "/_cluster/health":
+			replyJson(w, `{"cluster_name":"apmtest","status":"green"}`)
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			var query json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			traceId := gjson.GetBytes(query, `query.term.trace\.id`).String()
+			s.replyTrace(w, traceId, http.StatusOK, `{"hits":{"total":{"value":0},"hits":[]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
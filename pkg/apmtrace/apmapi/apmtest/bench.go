@@ -0,0 +1,34 @@
+package apmtest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSpanCounts are the sizes of the synthetic traces, 50k spans is the size of a large Kafka fan-out.
+var BenchmarkSpanCounts = []int{1000, 10000, 50000}
+
+// FanOutConsumerServices is the count of consumer services in the synthetic fan-out traces.
+const FanOutConsumerServices = 8
+
+// RunConvertBenchmarks converts the raw response generated for every size of BenchmarkSpanCounts,
+// eg. go test -run ^$ -bench ConvertFixture -benchmem ./pkg/apmtrace/apmapi/...
+func RunConvertBenchmarks(b *testing.B, generate func(spans int) []byte, convert func(data []byte) error) {
+	for _, spans := range BenchmarkSpanCounts {
+		data := generate(spans)
+		b.Run(fmt.Sprintf("spans=%d", spans), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := convert(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// FanOutConsumer returns the consumer service of the i-th message in the synthetic fan-out traces.
+func FanOutConsumer(i int) string {
+	return fmt.Sprintf("kafka-consumer-%d", i%FanOutConsumerServices)
+}
@@ -0,0 +1,103 @@
+package apmtest
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+)
+
+// RunQueryTests serves every case of testdata by server and queries it through api, the service trees are compared with validate.json.
+// The faults of server are then injected one by one and the returned ErrorCode is checked,
+// timeout is the timeout configured into api and the latency of the timeout case exceeds it.
+func RunQueryTests(t *testing.T, server *FakeServer, api apmapi.QueryByApmApi, timeout time.Duration, testdata string) {
+	t.Helper()
+	cases, err := server.AddTestdata(testdata)
+	if err != nil {
+		t.Fatalf("Add testdata failed, Error: %v", err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("No data.json is found in %s", testdata)
+	}
+	caseDirs := make([]string, 0, len(cases))
+	for caseDir := range cases {
+		caseDirs = append(caseDirs, caseDir)
+	}
+	sort.Strings(caseDirs)
+	for _, caseDir := range caseDirs {
+		caseDir := caseDir
+		t.Run(filepath.Base(caseDir), func(t *testing.T) {
+			CheckQueryCase(t, api, cases[caseDir], caseDir)
+		})
+	}
+
+	traceId := cases[caseDirs[0]]
+	t.Run("notFound", func(t *testing.T) {
+		CheckErrorCode(t, api, "unknown-trace-id", apmapi.ErrCodeNotFound)
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		server.SetBasicAuth("apmtest", "secret")
+		defer server.SetBasicAuth("", "")
+		CheckErrorCode(t, api, traceId, apmapi.ErrCodeUnauthorized)
+	})
+	t.Run("upstreamError", func(t *testing.T) {
+		server.SetFault(Fault{Status: http.StatusInternalServerError})
+		defer server.SetFault(Fault{})
+		CheckErrorCode(t, api, traceId, apmapi.ErrCodeUpstreamUnavailable)
+	})
+	t.Run("malformed", func(t *testing.T) {
+		server.SetFault(Fault{Body: []byte(`{"truncated`)})
+		defer server.SetFault(Fault{})
+		CheckErrorCode(t, api, traceId, apmapi.ErrCodeMalformedResponse)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		server.SetFault(Fault{Latency: timeout + 500*time.Millisecond})
+		defer server.SetFault(Fault{})
+		CheckErrorCode(t, api, traceId, apmapi.ErrCodeTimeout)
+	})
+
+	healthApi, ok := api.(apmapi.HealthCheckApi)
+	if !ok {
+		return
+	}
+	t.Run("health", func(t *testing.T) {
+		if err := healthApi.CheckHealth(); err != nil {
+			t.Errorf("[Check health] want=nil, got=%v", err)
+		}
+		server.SetBasicAuth("apmtest", "secret")
+		defer server.SetBasicAuth("", "")
+		if got := apmapi.GetErrorCode(healthApi.CheckHealth()); got != apmapi.ErrCodeUnauthorized {
+			t.Errorf("[Check health unauthorized] want=%s, got=%s", apmapi.ErrCodeUnauthorized, got)
+		}
+	})
+}
+
+// CheckQueryCase queries traceId through api and compares the service tree with validate.json of caseDir.
+func CheckQueryCase(t *testing.T, api apmapi.QueryByApmApi, traceId string, caseDir string) {
+	t.Helper()
+	expect, err := golden.ReadTraceCase(filepath.Join(caseDir, golden.ValidateFile))
+	if err != nil {
+		t.Fatalf("Read golden failed, Error: %v", err)
+	}
+	services, err := api.QueryList(traceId, 0, "")
+	if err != nil {
+		t.Fatalf("[Check QueryList] want=nil, got=%v", err)
+	}
+	if diffs := golden.DiffServices(expect.Services, services); len(diffs) > 0 {
+		t.Errorf("[Check %s] service tree mismatch:\n%s", caseDir, strings.Join(diffs, "\n"))
+	}
+}
+
+// CheckErrorCode queries traceId through api and checks the ErrorCode of the returned error.
+func CheckErrorCode(t *testing.T, api apmapi.QueryByApmApi, traceId string, expect apmapi.ErrorCode) {
+	t.Helper()
+	_, err := api.QueryList(traceId, 0, "")
+	if got := apmapi.GetErrorCode(err); got != expect {
+		t.Errorf("[Check ErrorCode] want=%s, got=%s (%v)", expect, got, err)
+	}
+}
@@ -0,0 +1,108 @@
+package apmtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+)
+
+// maxDiffs limits the reported diffs of a case, the rest is usually caused by the first ones.
+const maxDiffs = 50
+
+// RunTraceCases runs every <dir>/<apm>/<case> as subtest <apm>/<case>, validate.json is rewritten instead if update is set.
+func RunTraceCases(t *testing.T, dir string, update bool) {
+	t.Helper()
+	apmDirs, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Read trace cases failed, Error: %v", err)
+	}
+	for _, apmDir := range apmDirs {
+		if !apmDir.IsDir() {
+			continue
+		}
+		apm := apmDir.Name()
+		t.Run(apm, func(t *testing.T) {
+			RunBackendCases(t, apm, filepath.Join(dir, apm), update)
+		})
+	}
+}
+
+// RunBackendCases runs every <dir>/<case> by the backend registered as apm,
+// the suffix of apm is ignored, eg. jaeger-1.32 is converted by jaeger.
+func RunBackendCases(t *testing.T, apm string, dir string, update bool) {
+	t.Helper()
+	backendName, _, _ := strings.Cut(apm, "-")
+	backend, exist := apmapi.GetBackend(backendName)
+	if !exist || backend.ConvertFixture == nil {
+		t.Fatalf("Unknown apmType: %s, the backend package is not imported or has no ConvertFixture", apm)
+	}
+	caseDirs, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Read trace cases failed, Error: %v", err)
+	}
+	for _, caseDir := range caseDirs {
+		if !caseDir.IsDir() || !fileExist(filepath.Join(dir, caseDir.Name(), golden.DataFile)) {
+			continue
+		}
+		testCase := caseDir.Name()
+		t.Run(testCase, func(t *testing.T) {
+			RunTraceCase(t, backend, fmt.Sprintf("%s-%s", apm, testCase), filepath.Join(dir, testCase), update)
+		})
+	}
+}
+
+// RunTraceCase converts data.json of caseDir and compares it with validate.json, or rewrites validate.json if update is set.
+func RunTraceCase(t *testing.T, backend *apmapi.Backend, name string, caseDir string, update bool) {
+	t.Helper()
+	got, err := golden.ConvertTraceCase(backend, name, caseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goldenFile := filepath.Join(caseDir, golden.ValidateFile)
+	if update {
+		if err = golden.WriteTraceCase(goldenFile, got); err != nil {
+			t.Fatalf("Update %s failed, Error: %v", goldenFile, err)
+		}
+		return
+	}
+	expect, err := golden.ReadTraceCase(goldenFile)
+	if err != nil {
+		t.Fatalf("Read %s failed, run the test with -update to create it, Error: %v", goldenFile, err)
+	}
+	if diffs := golden.DiffTraceCase(expect, got); len(diffs) > 0 {
+		if len(diffs) > maxDiffs {
+			diffs = append(diffs[:maxDiffs], fmt.Sprintf("... %d more diffs", len(diffs)-maxDiffs))
+		}
+		t.Errorf("%s mismatch, run the test with -update if the change is expected:\n%s", goldenFile, strings.Join(diffs, "\n"))
+	}
+}
+
+// AddFuzzSeeds adds every <dir>/<case>/data.json to the seed corpus of a fuzz target.
+func AddFuzzSeeds(f *testing.F, dir string) {
+	f.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*", golden.DataFile))
+	if err != nil {
+		f.Fatalf("Glob seeds failed, Error: %v", err)
+	}
+	if len(files) == 0 {
+		f.Fatalf("No seed is found in %s", dir)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatalf("Read seed failed, Error: %v", err)
+		}
+		f.Add(data)
+	}
+}
+
+func fileExist(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,169 @@
+// Package apmtest provides httptest based fakes of the APM backends, they serve the fixtures in testdata
+// and inject latency, error status, malformed bodies and auth failures for request path tests.
+package apmtest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+// Fault changes the replies of the trace queries, the zero value replies the stored fixtures.
+type Fault struct {
+	// Latency delays every reply, including health checks, eg. to trigger the client timeout.
+	Latency time.Duration
+	// Status replies the status without body to trace queries if not 0.
+	Status int
+	// Body replaces the fixture of trace queries if not nil, eg. malformed json.
+	Body []byte
+}
+
+// FakeServer is the fake of one backend, traces are stored as the raw upstream response by traceId.
+type FakeServer struct {
+	*httptest.Server
+
+	backend string
+	route   func(s *FakeServer, w http.ResponseWriter, r *http.Request)
+
+	lock          sync.RWMutex
+	traces        map[string][]byte
+	fault         Fault
+	authorization string
+	requests      int
+}
+
+func newFakeServer(backend string, route func(s *FakeServer, w http.ResponseWriter, r *http.Request)) *FakeServer {
+	s := &FakeServer{
+		backend: backend,
+		route:   route,
+		traces:  make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Address returns host:port, which is the address format of the backend configs.
+func (s *FakeServer) Address() string {
+	return s.Listener.Addr().String()
+}
+
+// AddTrace stores the raw upstream response replied for traceId.
+func (s *FakeServer) AddTrace(traceId string, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.traces[traceId] = data
+}
+
+// AddFixture stores a data.json, the traceId is read by the ConvertFixture of the backend.
+func (s *FakeServer) AddFixture(path string) (string, error) {
+	backend, exist := apmapi.GetBackend(s.backend)
+	if !exist || backend.ConvertFixture == nil {
+		return "", fmt.Errorf("backend %s is not registered", s.backend)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	traceId, _, err := backend.ConvertFixture(data)
+	if err != nil {
+		return "", fmt.Errorf("convert %s: %w", path, err)
+	}
+	s.AddTrace(traceId, data)
+	return traceId, nil
+}
+
+// AddTestdata stores every <dir>/<case>/data.json and returns the traceId by case directory.
+func (s *FakeServer) AddTestdata(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "data.json"))
+	if err != nil {
+		return nil, err
+	}
+	cases := make(map[string]string, len(files))
+	for _, file := range files {
+		traceId, err := s.AddFixture(file)
+		if err != nil {
+			return nil, err
+		}
+		cases[filepath.Dir(file)] = traceId
+	}
+	return cases, nil
+}
+
+// SetFault changes the replies from the next request.
+func (s *FakeServer) SetFault(fault Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fault = fault
+}
+
+// SetBasicAuth makes the server reply 401 unless the request carries the credentials, empty user disables the check.
+func (s *FakeServer) SetBasicAuth(user string, password string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if user == "" {
+		s.authorization = ""
+		return
+	}
+	s.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// Requests returns the count of received requests.
+func (s *FakeServer) Requests() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.requests
+}
+
+func (s *FakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.requests++
+	fault := s.fault
+	authorization := s.authorization
+	s.lock.Unlock()
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if authorization != "" && r.Header.Get("Authorization") != authorization {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.route(s, w, r)
+}
+
+// replyTrace writes the stored trace, or notFoundBody with notFoundStatus if traceId is unknown.
+func (s *FakeServer) replyTrace(w http.ResponseWriter, traceId string, notFoundStatus int, notFoundBody string) {
+	s.lock.RLock()
+	fault := s.fault
+	data, exist := s.traces[traceId]
+	s.lock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case fault.Status != 0:
+		w.WriteHeader(fault.Status)
+	case fault.Body != nil:
+		w.Write(fault.Body)
+	case !exist:
+		w.WriteHeader(notFoundStatus)
+		w.Write([]byte(notFoundBody))
+	default:
+		w.Write(data)
+	}
+}
+
+func replyJson(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(body))
+}
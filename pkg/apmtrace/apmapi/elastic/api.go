@@ -1,10 +1,12 @@
 package elastic
 
 import (
+	"bytes"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 	"github.com/elastic/go-elasticsearch/v7"
 )
@@ -41,10 +43,47 @@ func NewELASTICApi(addr string, username string, password string, timeout int64)
 }
 
 func (api *ELASTICApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
-	searchResp, err := api.searchSpans(traceId, "apm-*-span", "apm-*-transaction", "apm-*-error")
+	return api.QueryListWithOptions(traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+}
+
+func (api *ELASTICApi) QueryListWithOptions(traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, error) {
+	builder := newTraceBuilder()
+	count, err := api.searchHits(traceId, builder.addHit, "apm-*-span", "apm-*-transaction", "apm-*-error")
 	if err != nil {
 		return nil, err
 	}
+	if count == 0 {
+		return nil, apmapi.NewNotFoundError("[x Trace NotFound] Elastic traceId: %s", traceId)
+	}
+
+	return builder.build(opts.ClockSkew)
+}
 
-	return ConvertToServiceNodes(searchResp)
+// ConvertRaw converts a response of QueryRaw, QueryListWithOptions decodes the same hits from the search stream.
+func (api *ELASTICApi) ConvertRaw(traceId string, data []byte, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	builder := newTraceBuilder()
+	count, err := DecodeSearchHits(bytes.NewReader(data), builder.addHit)
+	if err != nil {
+		return nil, false, apmapi.WrapDecodeError("elastic", err)
+	}
+	if count == 0 {
+		return nil, false, apmapi.NewNotFoundError("[x Trace NotFound] Elastic traceId: %s", traceId)
+	}
+	services, err := builder.build(opts.ClockSkew)
+	return services, true, err
+}
+
+func (api *ELASTICApi) QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error) {
+	return api.searchRaw(traceId, "apm-*-span", "apm-*-transaction", "apm-*-error")
+}
+
+func (api *ELASTICApi) CheckHealth() error {
+	health, err := api.clusterHealth()
+	if err != nil {
+		return err
+	}
+	if health.Status == "red" {
+		return apmapi.NewUpstreamUnavailableError(nil, "[x Unhealthy] Elasticsearch cluster %s status: %s", health.ClusterName, health.Status)
+	}
+	return nil
 }
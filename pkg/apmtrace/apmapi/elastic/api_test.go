@@ -0,0 +1,32 @@
+package elastic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/elastic"
+)
+
+func TestElasticQueryList(t *testing.T) {
+	server := apmtest.NewElasticServer()
+	defer server.Close()
+
+	api, err := elastic.NewELASTICApi(server.Address(), "", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apmtest.RunQueryTests(t, server, api, time.Second, "../testdata/tracelist/elastic")
+
+	t.Run("basicAuth", func(t *testing.T) {
+		server.SetBasicAuth("apmtest", "secret")
+		defer server.SetBasicAuth("", "")
+		authApi, err := elastic.NewELASTICApi(server.Address(), "apmtest", "secret", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := authApi.CheckHealth(); err != nil {
+			t.Errorf("[Check health with credentials] want=nil, got=%v", err)
+		}
+	})
+}
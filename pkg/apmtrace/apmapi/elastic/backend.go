@@ -0,0 +1,64 @@
+package elastic
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	BackendName = "elastic"
+	ApmType     = "elastic"
+)
+
+type Config struct {
+	Address  string `mapstructure:"address"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password" secret:"true"`
+}
+
+func (conf *Config) Validate(prefix string) error {
+	return config.ValidateURLAddress(prefix+".address", conf.Address)
+}
+
+func init() {
+	apmapi.Register(&apmapi.Backend{
+		Name:    BackendName,
+		ApmType: ApmType,
+		NewConfig: func() any {
+			return &Config{}
+		},
+		NewApi: func(conf any, timeout int64) (apmapi.QueryByApmApi, error) {
+			esConf := conf.(*Config)
+			if len(esConf.Address) == 0 {
+				return nil, errors.New("elastic.address is not set")
+			}
+			return NewELASTICApi(esConf.Address, esConf.User, esConf.Password, timeout)
+		},
+		ConvertFixture: convertFixture,
+	})
+}
+
+func convertFixture(data []byte) (string, []*model.OtelServiceNode, error) {
+	var traceId string
+	builder := newTraceBuilder()
+	_, err := DecodeSearchHits(bytes.NewReader(data), func(hit *UnpackerHit) {
+		if traceId == "" {
+			traceId = gjson.GetBytes(hit.Source, "trace.id").String()
+		}
+		builder.addHit(hit)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	services, err := builder.build(nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return traceId, services, nil
+}
@@ -0,0 +1,109 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+func BenchmarkConvertFixture(b *testing.B) {
+	apmtest.RunConvertBenchmarks(b, generateFanOutTrace, func(data []byte) error {
+		_, _, err := convertFixture(data)
+		return err
+	})
+}
+
+// generateFanOutTrace returns the hits of one producer transaction sending (spans-1)/2 messages, each consumed by a transaction
+// of one of the consumer services. Every 100th consumer transaction reports an error with a 20 frames stacktrace.
+func generateFanOutTrace(spans int) []byte {
+	const traceId = "0af7651916cd43dd8448eb211c80319c"
+	messages := (spans - 1) / 2
+	startTime := int64(1706168391553000)
+
+	hits := make([]map[string]any, 0, 1+2*messages+messages/100)
+	hits = append(hits, newBenchHit("transaction", map[string]any{
+		"trace":       map[string]any{"id": traceId},
+		"timestamp":   map[string]any{"us": startTime},
+		"service":     map[string]any{"name": "kafka-producer"},
+		"event":       map[string]any{"outcome": "success"},
+		"url":         map[string]any{"full": "http://kafka-producer:8080/orders/publish"},
+		"transaction": map[string]any{"id": "r", "name": "POST /orders/publish", "type": "request", "result": "HTTP 2xx", "duration": map[string]any{"us": messages}},
+	}))
+	for i := 1; i <= messages; i++ {
+		spanId := fmt.Sprintf("p%015x", i)
+		hits = append(hits, newBenchHit("span", map[string]any{
+			"trace":       map[string]any{"id": traceId},
+			"parent":      map[string]any{"id": "r"},
+			"timestamp":   map[string]any{"us": startTime + int64(i)},
+			"service":     map[string]any{"name": "kafka-producer"},
+			"event":       map[string]any{"outcome": "success"},
+			"transaction": map[string]any{"id": "r"},
+			"span": map[string]any{
+				"id": spanId, "name": "KafkaProducer#send to orders", "type": "messaging", "subtype": "kafka",
+				"duration":    map[string]any{"us": 10},
+				"destination": map[string]any{"service": map[string]any{"resource": "kafka/orders"}},
+			},
+		}))
+		transactionId := fmt.Sprintf("c%015x", i)
+		outcome := "success"
+		if i%100 == 0 {
+			outcome = "failure"
+		}
+		hits = append(hits, newBenchHit("transaction", map[string]any{
+			"trace":       map[string]any{"id": traceId},
+			"parent":      map[string]any{"id": spanId},
+			"timestamp":   map[string]any{"us": startTime + int64(i) + 20},
+			"service":     map[string]any{"name": apmtest.FanOutConsumer(i)},
+			"event":       map[string]any{"outcome": outcome},
+			"transaction": map[string]any{"id": transactionId, "name": "Kafka record from orders", "type": "messaging", "result": "success", "duration": map[string]any{"us": 30}},
+		}))
+		if outcome == "failure" {
+			hits = append(hits, newBenchHit("error", newBenchError(traceId, transactionId, startTime+int64(i)+40, apmtest.FanOutConsumer(i))))
+		}
+	}
+	data, _ := json.Marshal(map[string]any{
+		"hits": map[string]any{
+			"total": map[string]any{"value": len(hits)},
+			"hits":  hits,
+		},
+	})
+	return data
+}
+
+func newBenchHit(event string, source map[string]any) map[string]any {
+	source["processor"] = map[string]any{"name": event, "event": event}
+	return map[string]any{
+		"_index":  "apm-7.17.20-" + event + "-000001",
+		"_source": source,
+		"fields":  map[string]any{"processor.event": []string{event}},
+	}
+}
+
+func newBenchError(traceId string, parentId string, timestamp int64, service string) map[string]any {
+	frames := make([]map[string]any, 0, 20)
+	for i := 0; i < 20; i++ {
+		frames = append(frames, map[string]any{
+			"filename":  "OrderListener.java",
+			"classname": "com.example.orders.OrderListener",
+			"function":  fmt.Sprintf("handle%d", i),
+			"line":      map[string]any{"number": 100 + i},
+		})
+	}
+	return map[string]any{
+		"trace":       map[string]any{"id": traceId},
+		"parent":      map[string]any{"id": parentId},
+		"timestamp":   map[string]any{"us": timestamp},
+		"service":     map[string]any{"name": service},
+		"transaction": map[string]any{"id": parentId},
+		"error": map[string]any{
+			"id": parentId + "-error",
+			"exception": []map[string]any{{
+				"type":       "java.lang.IllegalStateException",
+				"message":    "order is already processed",
+				"stacktrace": frames,
+			}},
+		},
+	}
+}
@@ -1,5 +1,24 @@
 package elastic
 
+import (
+	"strconv"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+// The span.type, span.subtype and span.action which decide the kind and the attributes of the span.
+const (
+	TypeMessaging = "messaging"
+	TypeDB        = "db"
+	TypeCache     = "cache"
+	TypeExternal  = "external"
+
+	SubtypeHTTP = "http"
+
+	ActionSend    = "send"
+	ActionReceive = "receive"
+)
+
 type Agent struct {
 	Name        string `json:"name"`
 	EphemeralID string `json:"ephemeral_id"`
@@ -49,6 +68,15 @@ type Processor struct {
 	Event string `json:"event"`
 }
 
+// Message is the message handled by a messaging span or transaction.
+type Message struct {
+	Queue MessageQueue `json:"queue"`
+}
+
+type MessageQueue struct {
+	Name string `json:"name"`
+}
+
 type Timestamp struct {
 	Us int64 `json:"us"`
 }
@@ -67,8 +95,50 @@ type Line struct {
 	Number int64 `json:"number"`
 }
 
+// Host skips ip which is a string in 7.x and an array in 8.x.
 type Host struct {
+	Hostname     string `json:"hostname"`
+	Name         string `json:"name"`
 	OS           OS     `json:"os"`
-	IP           string `json:"ip"`
 	Architecture string `json:"architecture"`
 }
+
+// Resource are the ECS fields of the agent shared by spans and transactions, they are set as the resource attributes.
+type Resource struct {
+	Host       *Host       `json:"host"`
+	Kubernetes *Kubernetes `json:"kubernetes"`
+	Container  *Container  `json:"container"`
+	Process    *Process    `json:"process"`
+}
+
+// setResourceAttributes sets the resource of the agent, node is service.node which names the instance.
+func (r *Resource) setResourceAttributes(attributes map[string]string, node *Node) {
+	if node != nil {
+		setNonEmpty(attributes, apmapi.AttributeServiceInstanceID, node.Name)
+	}
+	if r.Host != nil {
+		if r.Host.Name != "" {
+			setNonEmpty(attributes, apmapi.AttributeHostName, r.Host.Name)
+		} else {
+			setNonEmpty(attributes, apmapi.AttributeHostName, r.Host.Hostname)
+		}
+	}
+	if r.Kubernetes != nil {
+		setNonEmpty(attributes, apmapi.AttributeK8sNamespaceName, r.Kubernetes.Namespace)
+		setNonEmpty(attributes, apmapi.AttributeK8sNodeName, r.Kubernetes.Node.Name)
+		setNonEmpty(attributes, apmapi.AttributeK8sPodName, r.Kubernetes.Pod.Name)
+		setNonEmpty(attributes, apmapi.AttributeK8sPodUID, r.Kubernetes.Pod.Uid)
+	}
+	if r.Container != nil {
+		setNonEmpty(attributes, apmapi.AttributeContainerID, r.Container.ID)
+	}
+	if r.Process != nil && r.Process.PID > 0 {
+		attributes[apmapi.AttributeProcessPID] = strconv.FormatInt(r.Process.PID, 10)
+	}
+}
+
+func setNonEmpty(attributes map[string]string, key string, value string) {
+	if value != "" {
+		attributes[key] = value
+	}
+}
@@ -3,53 +3,93 @@ package elastic
 import (
 	"encoding/json"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
-	cmodel "github.com/CloudDetail/apo-module/model/v1"
 )
 
-func ConvertToServiceNodes(resp *SearchResp) ([]*model.OtelServiceNode, error) {
-	var otelSpans = []*model.OtelSpan{}
-	var otelSpanMap = map[string]*model.OtelSpan{}
+// ConvertToServiceNodes converts the hits, the spans are adjusted by adjuster before the relations are built unless it is nil.
+func ConvertToServiceNodes(resp *SearchResp, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	builder := newTraceBuilder()
 	for i := 0; i < len(resp.Hits.Hits); i++ {
-		hit := resp.Hits.Hits[i]
+		builder.addHit(&resp.Hits.Hits[i])
+	}
+	return builder.build(adjuster)
+}
+
+// traceBuilder converts the hits one by one, so the hits can be streamed from the response.
+type traceBuilder struct {
+	otelSpans   []*model.OtelSpan
+	otelSpanMap map[string]*model.OtelSpan
+	errors      []*errorRef
+	// stackBuf is reused by the stacktraces of all exceptions.
+	stackBuf []byte
+}
+
+func newTraceBuilder() *traceBuilder {
+	return &traceBuilder{
+		otelSpans:   []*model.OtelSpan{},
+		otelSpanMap: map[string]*model.OtelSpan{},
+	}
+}
+
+// addHit converts the hit, it keeps neither the hit nor its Source.
+func (b *traceBuilder) addHit(hit *UnpackerHit) {
+	switch GetProcessEvent(hit) {
+	case SpanProcessor:
+		otelSpan := rawSpanToOtelSpan(hit.Source)
+		if otelSpan != nil {
+			b.otelSpanMap[otelSpan.SpanId] = otelSpan
+			b.otelSpans = append(b.otelSpans, otelSpan)
+		}
+	case TransactionProcessor:
+		otelSpan := rawTransactionToOtelSpan(hit.Source)
+		if otelSpan != nil {
+			b.otelSpanMap[otelSpan.SpanId] = otelSpan
+			b.otelSpans = append(b.otelSpans, otelSpan)
+		}
+	case ErrorProcessor:
+		var ref *errorRef
+		ref, b.stackBuf = rawErrorToException(hit.Source, b.stackBuf)
+		if ref != nil && len(ref.exceptions) > 0 {
+			b.errors = append(b.errors, ref)
+		}
+	}
+}
 
-		switch GetProcessEvent(&hit) {
-		case SpanProcessor:
-			otelSpan := rawSpanToOtelSpan(hit.Source)
-			if otelSpan != nil {
-				otelSpanMap[otelSpan.SpanId] = otelSpan
-				otelSpans = append(otelSpans, otelSpan)
-			}
-		case TransactionProcessor:
-			otelSpan := rawTransactionToOtelSpan(hit.Source)
-			if otelSpan != nil {
-				otelSpanMap[otelSpan.SpanId] = otelSpan
-				otelSpans = append(otelSpans, otelSpan)
-			}
-		case ErrorProcessor:
-			exceptions, parentId := rawErrorToException(hit.Source)
-			if len(exceptions) == 0 {
-				continue
-			}
-			if otelSpan, find := otelSpanMap[parentId]; find {
-				otelSpan.Exceptions = append(otelSpan.Exceptions, exceptions...)
-			}
-		default:
+// attachErrors sets the errors to their spans, an error whose span is not in the hits is set to its transaction.
+// The transaction owning an error is marked as errored as well as the span capturing it.
+func (b *traceBuilder) attachErrors() {
+	for _, ref := range b.errors {
+		entrySpan := b.otelSpanMap[ref.transactionId]
+		otelSpan, find := b.otelSpanMap[ref.parentId]
+		if !find {
+			otelSpan = entrySpan
+		}
+		if otelSpan == nil {
 			continue
 		}
+		otelSpan.Exceptions = append(otelSpan.Exceptions, ref.exceptions...)
+		otelSpan.Code = model.StatusCodeError
+		if entrySpan != nil {
+			entrySpan.Code = model.StatusCodeError
+		}
 	}
+}
 
+func (b *traceBuilder) build(adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	b.attachErrors()
 	traceData := model.NewOTelTrace("elastic")
-	if otelSpans == nil && len(otelSpans) == 0 {
+	if len(b.otelSpans) == 0 {
 		return traceData.GetServiceNodes(), nil
 	}
 
 	traceTree := model.NewOtelTree()
-	for _, span := range otelSpans {
+	for _, span := range b.otelSpans {
 		if err := traceTree.AddSpan(span); err != nil {
 			return nil, err
 		}
 	}
+	adjuster.Adjust(traceTree)
 	if err := traceTree.BuildRelation4Spans(traceData); err != nil {
 		return nil, err
 	}
@@ -81,15 +121,15 @@ func rawTransactionToOtelSpan(source json.RawMessage) *model.OtelSpan {
 	return convertTransToOtelSpan(transaction)
 }
 
-func rawErrorToException(source json.RawMessage) (exceptions []*cmodel.Exception, parentId string) {
+func rawErrorToException(source json.RawMessage, stackBuf []byte) (*errorRef, []byte) {
 	errorSpan := &ErrorSpan{}
 	err := json.Unmarshal(source, errorSpan)
 	if err != nil {
-		return nil, ""
+		return nil, stackBuf
 	}
-	if errorSpan.Parent.ID == "" {
-		return nil, ""
+	if errorSpan.Parent.ID == "" && errorSpan.Transaction.ID == "" {
+		return nil, stackBuf
 	}
 
-	return convertErrorToException(errorSpan)
+	return convertErrorToException(errorSpan, stackBuf)
 }
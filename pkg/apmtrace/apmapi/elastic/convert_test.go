@@ -0,0 +1,137 @@
+package elastic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestResourceAttributes(t *testing.T) {
+	source := json.RawMessage(`{
+		"trace": {"id": "t1"},
+		"timestamp": {"us": 1},
+		"service": {"name": "orders", "node": {"name": "orders-7d9f-1"}},
+		"transaction": {"id": "tx1", "name": "GET /orders", "result": "HTTP 2xx", "duration": {"us": 10}},
+		"host": {"hostname": "node-1", "ip": ["10.0.0.1"]},
+		"kubernetes": {"namespace": "shop", "node": {"name": "node-1"}, "pod": {"name": "orders-7d9f", "uid": "a1b2"}},
+		"container": {"id": "c0ffee"},
+		"process": {"pid": 42, "title": "java"}
+	}`)
+	span := rawTransactionToOtelSpan(source)
+	if span == nil {
+		t.Fatal("[Check transaction] want span, got nil")
+	}
+	expect := map[string]string{
+		"http.status_code":    "2xx",
+		"service.instance.id": "orders-7d9f-1",
+		"host.name":           "node-1",
+		"k8s.namespace.name":  "shop",
+		"k8s.node.name":       "node-1",
+		"k8s.pod.name":        "orders-7d9f",
+		"k8s.pod.uid":         "a1b2",
+		"container.id":        "c0ffee",
+		"process.pid":         "42",
+	}
+	if !reflect.DeepEqual(expect, span.Attributes) {
+		t.Errorf("[Check resource attributes] want=%v, got=%v", expect, span.Attributes)
+	}
+}
+
+func TestSpanKindAndSystem(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		kind   model.OtelSpanKind
+		expect map[string]string
+	}{
+		{
+			name:   "send",
+			source: `{"span": {"id": "s1", "type": "messaging", "subtype": "kafka", "action": "send", "destination": {"service": {"resource": "kafka/orders"}}}}`,
+			kind:   model.SpanKindProducer,
+			expect: map[string]string{"messaging.system": "kafka", "messaging.destination.name": "orders"},
+		},
+		{
+			name:   "receive",
+			source: `{"span": {"id": "s2", "type": "messaging", "subtype": "jms", "action": "receive", "message": {"queue": {"name": "invoices"}}}}`,
+			kind:   model.SpanKindConsumer,
+			expect: map[string]string{"messaging.system": "jms", "messaging.destination.name": "invoices"},
+		},
+		{
+			name:   "poll",
+			source: `{"span": {"id": "s3", "type": "messaging", "subtype": "rabbitmq", "action": "poll", "destination": {"service": {"resource": "rabbitmq"}}}}`,
+			kind:   model.SpanKindClient,
+			expect: map[string]string{"messaging.system": "rabbitmq"},
+		},
+		{
+			name:   "rpc",
+			source: `{"span": {"id": "s4", "type": "external", "subtype": "grpc", "destination": {"service": {"resource": "orders:50051"}}}}`,
+			kind:   model.SpanKindClient,
+			expect: map[string]string{"rpc.system": "grpc"},
+		},
+		{
+			name:   "cache",
+			source: `{"span": {"id": "s5", "type": "cache", "subtype": "memcached"}}`,
+			kind:   model.SpanKindInternal,
+			expect: map[string]string{"db.system": "memcached"},
+		},
+	}
+	for _, test := range tests {
+		span := rawSpanToOtelSpan(json.RawMessage(test.source))
+		if span == nil {
+			t.Fatalf("[Check %s] want span, got nil", test.name)
+		}
+		if span.Kind != test.kind {
+			t.Errorf("[Check %s kind] want=%v, got=%v", test.name, test.kind, span.Kind)
+		}
+		if !reflect.DeepEqual(test.expect, span.Attributes) {
+			t.Errorf("[Check %s attributes] want=%v, got=%v", test.name, test.expect, span.Attributes)
+		}
+	}
+}
+
+func TestAttachErrors(t *testing.T) {
+	hit := func(event ProcessorEvent, source string) *UnpackerHit {
+		return &UnpackerHit{
+			Source: json.RawMessage(source),
+			Fields: map[string][]string{"processor.event": {string(event)}},
+		}
+	}
+	builder := newTraceBuilder()
+	// The errors are returned before their spans.
+	builder.addHit(hit(ErrorProcessor, `{"parent": {"id": "s1"}, "transaction": {"id": "t1"}, "timestamp": {"us": 3},
+		"error": {"exception": [{"type": "java.net.SocketTimeoutException", "message": "Read timed out"}]}}`))
+	builder.addHit(hit(ErrorProcessor, `{"parent": {"id": "s-missing"}, "transaction": {"id": "t1"}, "timestamp": {"us": 4},
+		"error": {"exception": [{"type": "java.lang.IllegalStateException", "message": "closed"}]}}`))
+	builder.addHit(hit(ErrorProcessor, `{"parent": {"id": "t1"}, "transaction": {"id": "t1"}, "timestamp": {"us": 5},
+		"error": {"log": {"message": "order 42 rejected", "level": "error", "logger_name": "com.shop.OrderService"}}}`))
+	builder.addHit(hit(ErrorProcessor, `{"parent": {"id": "s-missing"}, "transaction": {"id": "t-missing"}, "timestamp": {"us": 6},
+		"error": {"exception": [{"type": "java.lang.RuntimeException", "message": "lost"}]}}`))
+	builder.addHit(hit(TransactionProcessor, `{"service": {"name": "orders"}, "timestamp": {"us": 1}, "event": {"outcome": "success"},
+		"transaction": {"id": "t1", "name": "GET /orders", "duration": {"us": 10}}}`))
+	builder.addHit(hit(SpanProcessor, `{"service": {"name": "orders"}, "timestamp": {"us": 2}, "parent": {"id": "t1"}, "event": {"outcome": "success"},
+		"span": {"id": "s1", "name": "GET stock", "type": "external", "subtype": "http", "duration": {"us": 5}}}`))
+	builder.attachErrors()
+
+	expect := map[string][]string{
+		"s1": {"java.net.SocketTimeoutException"},
+		"t1": {"java.lang.IllegalStateException", "com.shop.OrderService"},
+	}
+	for spanId, types := range expect {
+		span := builder.otelSpanMap[spanId]
+		got := make([]string, 0, len(span.Exceptions))
+		for _, exception := range span.Exceptions {
+			got = append(got, exception.Type)
+		}
+		if !reflect.DeepEqual(types, got) {
+			t.Errorf("[Check %s exceptions] want=%v, got=%v", spanId, types, got)
+		}
+		if span.Code != model.StatusCodeError {
+			t.Errorf("[Check %s code] want=%v, got=%v", spanId, model.StatusCodeError, span.Code)
+		}
+	}
+	if stack := builder.otelSpanMap["t1"].Exceptions[1].Stack; stack != "order 42 rejected\n" {
+		t.Errorf("[Check error.log stack] want=%q, got=%q", "order 42 rejected\n", stack)
+	}
+}
@@ -2,7 +2,6 @@ package elastic
 
 import (
 	"strconv"
-	"strings"
 
 	cmodel "github.com/CloudDetail/apo-module/model/v1"
 )
@@ -30,6 +29,7 @@ type ErrorSpan struct {
 
 type Error struct {
 	Exception    []Exception `json:"exception"`
+	Log          *ErrorLog   `json:"log"`
 	ID           string      `json:"id"`
 	GroupingKey  string      `json:"grouping_key"`
 	GroupingName string      `json:"grouping_name"`
@@ -41,25 +41,46 @@ type Exception struct {
 	Type       string       `json:"type"`
 }
 
+// ErrorLog is the error reported by a logging call, eg. logger.error("...", e) without a captured exception.
+type ErrorLog struct {
+	Stacktrace []Stacktrace `json:"stacktrace"`
+	Message    string       `json:"message"`
+	Level      string       `json:"level"`
+	LoggerName string       `json:"logger_name"`
+}
+
 func (e *Exception) GetStacktrace() string {
-	var strBuf strings.Builder
-
-	strBuf.WriteString(e.Message)
-	strBuf.WriteByte('\n')
-	for i := 0; i < len(e.Stacktrace); i++ {
-		strBuf.WriteString("  at ")
-		strBuf.WriteString(e.Stacktrace[i].Classname)
-		strBuf.WriteByte('.')
-		strBuf.WriteString(e.Stacktrace[i].Function)
-		strBuf.WriteByte('(')
-		strBuf.WriteString(e.Stacktrace[i].Filename)
-		strBuf.WriteByte(':')
-		strBuf.WriteString(strconv.Itoa(int(e.Stacktrace[i].Line.Number)))
-		strBuf.WriteByte(')')
-		strBuf.WriteString("\n")
+	return string(e.AppendStacktrace(nil))
+}
+
+// AppendStacktrace appends the stacktrace to dst, so one buffer can be reused by all exceptions of a trace.
+func (e *Exception) AppendStacktrace(dst []byte) []byte {
+	return appendStacktrace(dst, e.Message, e.Stacktrace)
+}
+
+// Type is the logger of the error log, the exception type is not known.
+func (l *ErrorLog) Type() string {
+	if l.LoggerName != "" {
+		return l.LoggerName
 	}
+	return "log"
+}
 
-	return strBuf.String()
+func appendStacktrace(dst []byte, message string, stacktrace []Stacktrace) []byte {
+	dst = append(dst, message...)
+	dst = append(dst, '\n')
+	for i := 0; i < len(stacktrace); i++ {
+		dst = append(dst, "  at "...)
+		dst = append(dst, stacktrace[i].Classname...)
+		dst = append(dst, '.')
+		dst = append(dst, stacktrace[i].Function...)
+		dst = append(dst, '(')
+		dst = append(dst, stacktrace[i].Filename...)
+		dst = append(dst, ':')
+		dst = strconv.AppendInt(dst, stacktrace[i].Line.Number, 10)
+		dst = append(dst, ")\n"...)
+	}
+	return dst
 }
 
 type Language struct {
@@ -72,20 +93,44 @@ type ErrorTransaction struct {
 	Sampled bool   `json:"sampled"`
 }
 
-func convertErrorToException(errorSpan *ErrorSpan) ([]*cmodel.Exception, string) {
+// errorRef is a converted error waiting for its span, the errors are attached after all hits are added
+// since an error may be returned before its span.
+type errorRef struct {
+	exceptions []*cmodel.Exception
+	// parentId is the span or transaction capturing the error, transactionId is the transaction owning it.
+	parentId      string
+	transactionId string
+}
+
+// convertErrorToException builds the stacktraces in stackBuf and returns the grown buffer for the next error.
+func convertErrorToException(errorSpan *ErrorSpan, stackBuf []byte) (*errorRef, []byte) {
 	if errorSpan == nil {
-		return nil, ""
+		return nil, stackBuf
 	}
 
-	var exceptions = []*cmodel.Exception{}
+	ref := &errorRef{
+		exceptions:    make([]*cmodel.Exception, 0, len(errorSpan.Error.Exception)+1),
+		parentId:      errorSpan.Parent.ID,
+		transactionId: errorSpan.Transaction.ID,
+	}
 	for i := 0; i < len(errorSpan.Error.Exception); i++ {
+		stackBuf = errorSpan.Error.Exception[i].AppendStacktrace(stackBuf[:0])
 		exception := &cmodel.Exception{
 			Timestamp: uint64(errorSpan.Timestamp.Us),
 			Type:      errorSpan.Error.Exception[i].Type,
 			Message:   errorSpan.Error.Exception[i].Message,
-			Stack:     errorSpan.Error.Exception[i].GetStacktrace(),
+			Stack:     string(stackBuf),
 		}
-		exceptions = append(exceptions, exception)
+		ref.exceptions = append(ref.exceptions, exception)
+	}
+	if log := errorSpan.Error.Log; log != nil && log.Message != "" {
+		stackBuf = appendStacktrace(stackBuf[:0], log.Message, log.Stacktrace)
+		ref.exceptions = append(ref.exceptions, &cmodel.Exception{
+			Timestamp: uint64(errorSpan.Timestamp.Us),
+			Type:      log.Type(),
+			Message:   log.Message,
+			Stack:     string(stackBuf),
+		})
 	}
-	return exceptions, errorSpan.Parent.ID
+	return ref, stackBuf
 }
@@ -5,18 +5,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
 	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/tidwall/gjson"
 )
 
 var droppedFields = []string{
 	"span.stacktrace",
 	"observer",
-	"kubernetes",
-	"container",
 	"agent",
-	"process",
+	// The resource keeps process.pid.
+	"process.args",
 	"http.headers",
 	"response.headers",
 	"user_agent",
@@ -67,7 +69,112 @@ func GetProcessEvent(hit *UnpackerHit) ProcessorEvent {
 	return UnknownProcessor
 }
 
-func (c *ESClient) searchSpans(traceId string, indices ...string) (*SearchResp, error) {
+// DecodeSearchHits streams hits.hits of a search response to handle and returns the count of hits,
+// the hit is reused by the next one so handle must not keep it or its Source.
+func DecodeSearchHits(r io.Reader, handle func(hit *UnpackerHit)) (int, error) {
+	decoder := json.NewDecoder(r)
+	count := 0
+	hit := &UnpackerHit{}
+	err := decodeObject(decoder, func(key string) error {
+		if key != "hits" {
+			return skipValue(decoder)
+		}
+		return decodeObject(decoder, func(key string) error {
+			if key != "hits" {
+				return skipValue(decoder)
+			}
+			return decodeArray(decoder, func() error {
+				hit.Index = ""
+				hit.Source = hit.Source[:0]
+				clear(hit.Fields)
+				if err := decoder.Decode(hit); err != nil {
+					return err
+				}
+				count++
+				handle(hit)
+				return nil
+			})
+		})
+	})
+	return count, err
+}
+
+// decodeObject calls decodeValue with every key of an object, null is read as an empty object.
+func decodeObject(decoder *json.Decoder, decodeValue func(key string) error) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("expect object, got %v", token)
+	}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err = decodeValue(token.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// decodeArray calls decodeElement for every element of an array, null is read as an empty array.
+func decodeArray(decoder *json.Decoder, decodeElement func() error) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expect array, got %v", token)
+	}
+	for decoder.More() {
+		if err = decodeElement(); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+func skipValue(decoder *json.Decoder) error {
+	var skipped json.RawMessage
+	return decoder.Decode(&skipped)
+}
+
+// searchHits streams the hits of the search to handle, the response body is never read as a whole.
+func (c *ESClient) searchHits(traceId string, handle func(hit *UnpackerHit), indices ...string) (int, error) {
+	res, err := c.search(traceId, indices...)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	count, err := DecodeSearchHits(res.Body, handle)
+	if err != nil {
+		return 0, apmapi.WrapDecodeError("elastic", err)
+	}
+	return count, nil
+}
+
+func (c *ESClient) searchRaw(traceId string, indices ...string) ([]byte, error) {
+	res, err := c.search(traceId, indices...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("elastic", err)
+	}
+	return data, nil
+}
+
+// search returns the response of a successful search, the caller closes its body.
+func (c *ESClient) search(traceId string, indices ...string) (*esapi.Response, error) {
 	var buf bytes.Buffer
 	query := map[string]any{
 		"query": map[string]any{
@@ -93,18 +200,36 @@ func (c *ESClient) searchSpans(traceId string, indices ...string) (*SearchResp,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("search query error: %s", err)
+		return nil, apmapi.WrapRequestError("elastic", err)
+	}
+	if res.IsError() {
+		defer res.Body.Close()
+		return nil, fmt.Errorf("search query error: %s, %w", res.String(), apmapi.CheckResponseStatus("elastic", res.StatusCode))
+	}
+	return res, nil
+}
+
+type ClusterHealthResp struct {
+	ClusterName string `json:"cluster_name"`
+	Status      string `json:"status"`
+}
+
+func (c *ESClient) clusterHealth() (*ClusterHealthResp, error) {
+	res, err := c.es.Cluster.Health(
+		c.es.Cluster.Health.WithContext(context.Background()),
+	)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("elastic", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, fmt.Errorf("search query error: %s", res.String())
+		return nil, fmt.Errorf("cluster health error: %s, %w", res.String(), apmapi.CheckResponseStatus("elastic", res.StatusCode))
 	}
 
-	var result = SearchResp{}
+	var result = ClusterHealthResp{}
 	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error parsing the response body: %s", err)
+		return nil, apmapi.WrapDecodeError("elastic", err)
 	}
-
 	return &result, nil
 }
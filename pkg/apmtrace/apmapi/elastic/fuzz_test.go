@@ -0,0 +1,15 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+// FuzzConvertFixture checks that a malformed response is reported as error instead of panic, eg. go test -fuzz FuzzConvertFixture
+func FuzzConvertFixture(f *testing.F) {
+	apmtest.AddFuzzSeeds(f, "../testdata/tracelist/elastic")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = convertFixture(data)
+	})
+}
@@ -2,6 +2,7 @@ package elastic
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 	cmodel "github.com/CloudDetail/apo-module/model/v1"
@@ -21,6 +22,7 @@ type Span struct {
 	HTTP        *SpanHTTP        `json:"http"`
 	Destination *SpanDestination `json:"destination"`
 	Event       Event            `json:"event"`
+	Resource
 
 	// Observer     Observer `json:"observer"`
 	// Agent        Agent    `json:"agent"`
@@ -48,6 +50,14 @@ type SpanResponse struct {
 
 type SpanService struct {
 	Name string `json:"name"`
+	Node *Node  `json:"node"`
+	// Target is the downstream service set by 8.x agents, eg. the topic of a messaging span.
+	Target *ServiceTarget `json:"target"`
+}
+
+type ServiceTarget struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
 }
 
 type SpanClass struct {
@@ -55,11 +65,13 @@ type SpanClass struct {
 	Name     string   `json:"name"`
 	Type     string   `json:"type"`
 	Subtype  string   `json:"subtype"`
+	Action   string   `json:"action"`
 	Duration Duration `json:"duration"`
 
 	Destination *SpanDestinationClass `json:"destination"`
 	HTTP        *SpanHTTPClass        `json:"http"`
 	DB          *SpanDBClass          `json:"db"`
+	Message     *Message              `json:"message"`
 
 	// Stacktrace  []Stacktrace         `json:"stacktrace"`
 	HTTPURLOriginal string `json:"http.url.original"`
@@ -100,6 +112,14 @@ func (span *Span) Code() model.OtelStatusCode {
 }
 
 func (span *Span) SpanKind() model.OtelSpanKind {
+	if span.Span.Type == TypeMessaging {
+		switch span.Span.Action {
+		case ActionSend:
+			return model.SpanKindProducer
+		case ActionReceive:
+			return model.SpanKindConsumer
+		}
+	}
 	if span.Span.Destination != nil {
 		return model.SpanKindClient
 	}
@@ -136,5 +156,36 @@ func convertSpanToOtelSpan(span *Span) *model.OtelSpan {
 		otelSpan.Attributes[model.AttributeHTTPURL] = span.Span.HTTPURLOriginal
 		otelSpan.Attributes[model.AttributeHTTPStatusCode] = strconv.Itoa(span.Span.HTTP.Response.StatusCode)
 	}
+
+	// The subtype names the system, it is more specific than db.type which is sql for all sql databases.
+	switch span.Span.Type {
+	case TypeMessaging:
+		setNonEmpty(otelSpan.Attributes, model.AttributeMessageSystem, span.Span.Subtype)
+		setNonEmpty(otelSpan.Attributes, model.AttributeMessageDestinationName, span.messageDestination())
+	case TypeDB, TypeCache:
+		setNonEmpty(otelSpan.Attributes, model.AttributeDBSystem, span.Span.Subtype)
+	case TypeExternal:
+		if span.Span.Subtype != SubtypeHTTP {
+			setNonEmpty(otelSpan.Attributes, model.AttributeRpcSystem, span.Span.Subtype)
+		}
+	}
+	span.setResourceAttributes(otelSpan.Attributes, span.Service.Node)
 	return otelSpan
 }
+
+// messageDestination returns the queue or topic of the messaging span,
+// the agents not reporting message.queue.name are read from the target or the resource, eg. kafka/orders.
+func (span *Span) messageDestination() string {
+	if span.Span.Message != nil && span.Span.Message.Queue.Name != "" {
+		return span.Span.Message.Queue.Name
+	}
+	if span.Service.Target != nil && span.Service.Target.Name != "" {
+		return span.Service.Target.Name
+	}
+	if span.Span.Destination != nil {
+		if _, name, found := strings.Cut(span.Span.Destination.Service.Resource, "/"); found {
+			return name
+		}
+	}
+	return ""
+}
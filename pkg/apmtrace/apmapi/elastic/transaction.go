@@ -18,19 +18,16 @@ type Transaction struct {
 	URL   *URL  `json:"url"`
 	HTTP  *HTTP `json:"http"`
 	Event Event `json:"event"`
+	Resource
 
 	// Ignore Fields.
-	// Container            Container        `json:"container"`
-	// Kubernetes           Kubernetes       `json:"kubernetes"`
 	// Agent                Agent            `json:"agent"`
 	// Source               Client           `json:"source"`
 	// Observer             Observer         `json:"observer"`
 	// Ecs                  Ecs              `json:"ecs"`
-	// Host Host `json:"host"`
 	// Client               Client           `json:"client"`
 
 	// UserAgent            UserAgent        `json:"user_agent"`
-	// Process   Process   `json:"process"`
 	// TimestampStr   string           `json:"@timestamp"`
 }
 
@@ -61,7 +58,9 @@ type OS struct {
 }
 
 type Kubernetes struct {
-	Pod Pod `json:"pod"`
+	Namespace string `json:"namespace"`
+	Node      Node   `json:"node"`
+	Pod       Pod    `json:"pod"`
 }
 
 type Pod struct {
@@ -75,9 +74,9 @@ type Process struct {
 }
 
 type Service struct {
-	Name string `json:"name"`
-	// Node      Node      `json:"node"`
-	// Framework Framework `json:"framework"`
+	Name      string     `json:"name"`
+	Node      *Node      `json:"node"`
+	Framework *Framework `json:"framework"`
 	// Runtime   Framework `json:"runtime"`
 	// Language  Framework `json:"language"`
 	// Version   string    `json:"version"`
@@ -100,6 +99,7 @@ type TransactionClass struct {
 	ID        string    `json:"id"`
 	Type      string    `json:"type"`
 	Sampled   bool      `json:"sampled"`
+	Message   *Message  `json:"message"`
 }
 
 type Duration struct {
@@ -146,6 +146,14 @@ func (t *Transaction) Code() model.OtelStatusCode {
 	return model.StatusCodeError
 }
 
+// SpanKind is Consumer for the transactions started by receiving a message, eg. Kafka record from orders.
+func (t *Transaction) SpanKind() model.OtelSpanKind {
+	if t.Transaction.Type == TypeMessaging {
+		return model.SpanKindConsumer
+	}
+	return model.SpanKindServer
+}
+
 func convertTransToOtelSpan(t *Transaction) *model.OtelSpan {
 	if t == nil {
 		return nil
@@ -159,7 +167,7 @@ func convertTransToOtelSpan(t *Transaction) *model.OtelSpan {
 		SpanId:      t.Transaction.ID,
 		PSpanId:     t.ParentSpanId(),
 		// NextSpanId:  "",
-		Kind:       model.SpanKindServer,
+		Kind:       t.SpanKind(),
 		Code:       t.Code(),
 		Attributes: map[string]string{},
 	}
@@ -171,6 +179,16 @@ func convertTransToOtelSpan(t *Transaction) *model.OtelSpan {
 	if strings.HasPrefix(t.Transaction.Result, "HTTP ") {
 		entrySpan.Attributes[model.AttributeHTTPStatusCode] = t.Transaction.Result[5:]
 	}
+	if t.Transaction.Type == TypeMessaging {
+		// The agents name the messaging framework after the system, eg. Kafka, RabbitMQ.
+		if t.Service.Framework != nil {
+			setNonEmpty(entrySpan.Attributes, model.AttributeMessageSystem, strings.ToLower(t.Service.Framework.Name))
+		}
+		if t.Transaction.Message != nil {
+			setNonEmpty(entrySpan.Attributes, model.AttributeMessageDestinationName, t.Transaction.Message.Queue.Name)
+		}
+	}
+	t.setResourceAttributes(entrySpan.Attributes, t.Service.Node)
 
 	return entrySpan
 }
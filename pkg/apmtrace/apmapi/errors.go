@@ -0,0 +1,148 @@
+package apmapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+type ErrorCode string
+
+const (
+	ErrCodeBadRequest          ErrorCode = "BAD_REQUEST"
+	ErrCodeUnauthorized        ErrorCode = "UNAUTHORIZED"
+	ErrCodeNotFound            ErrorCode = "TRACE_NOT_FOUND"
+	ErrCodeIncomplete          ErrorCode = "TRACE_INCOMPLETE"
+	ErrCodeUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	ErrCodeTimeout             ErrorCode = "UPSTREAM_TIMEOUT"
+	ErrCodeMalformedResponse   ErrorCode = "MALFORMED_RESPONSE"
+	ErrCodeInternal            ErrorCode = "INTERNAL"
+)
+
+// ApmError is the typed error returned by backends, Code is stable and machine-readable.
+type ApmError struct {
+	Code    ErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ApmError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *ApmError) Unwrap() error {
+	return e.Err
+}
+
+func newApmError(code ErrorCode, err error, format string, args ...any) *ApmError {
+	return &ApmError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+func NewBadRequestError(format string, args ...any) error {
+	return newApmError(ErrCodeBadRequest, nil, format, args...)
+}
+
+func NewUnauthorizedError(format string, args ...any) error {
+	return newApmError(ErrCodeUnauthorized, nil, format, args...)
+}
+
+func NewNotFoundError(format string, args ...any) error {
+	return newApmError(ErrCodeNotFound, nil, format, args...)
+}
+
+func NewIncompleteError(format string, args ...any) error {
+	return newApmError(ErrCodeIncomplete, nil, format, args...)
+}
+
+func NewUpstreamUnavailableError(err error, format string, args ...any) error {
+	return newApmError(ErrCodeUpstreamUnavailable, err, format, args...)
+}
+
+func NewTimeoutError(err error, format string, args ...any) error {
+	return newApmError(ErrCodeTimeout, err, format, args...)
+}
+
+// NewMalformedResponseError reports an upstream response which can not be converted, eg. missing fields or wrong types.
+func NewMalformedResponseError(format string, args ...any) error {
+	return newApmError(ErrCodeMalformedResponse, nil, format, args...)
+}
+
+// WrapBadRequest marks err as caused by an invalid client request.
+func WrapBadRequest(err error) error {
+	if err == nil {
+		return nil
+	}
+	return newApmError(ErrCodeBadRequest, err, "[x Bad Request]")
+}
+
+// WrapRequestError classifies the error returned by an upstream http call as Timeout or UpstreamUnavailable.
+func WrapRequestError(apmType string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if IsTimeout(err) {
+		return NewTimeoutError(err, "[x Upstream Timeout] %s", apmType)
+	}
+	return NewUpstreamUnavailableError(err, "[x Upstream Unavailable] %s", apmType)
+}
+
+// WrapDecodeError classifies the error of decoding an upstream response as MalformedResponse,
+// a timeout while the body is still streamed is reported as Timeout.
+func WrapDecodeError(apmType string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if IsTimeout(err) {
+		return NewTimeoutError(err, "[x Upstream Timeout] %s", apmType)
+	}
+	return newApmError(ErrCodeMalformedResponse, err, "[x Malformed Response] %s", apmType)
+}
+
+// WrapConversionError reports the untyped error of converting a response as MalformedResponse, typed errors are kept, eg. NotFound.
+func WrapConversionError(apmType string, err error) error {
+	if err == nil || GetErrorCode(err) != ErrCodeInternal {
+		return err
+	}
+	return newApmError(ErrCodeMalformedResponse, err, "[x Malformed Response] %s", apmType)
+}
+
+// CheckResponseStatus converts a non successful upstream http status to the typed error.
+func CheckResponseStatus(apmType string, statusCode int) error {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return nil
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return NewUnauthorizedError("[x Not Authorized] %s status: %d", apmType, statusCode)
+	case statusCode == http.StatusGatewayTimeout || statusCode == http.StatusRequestTimeout:
+		return NewTimeoutError(nil, "[x Upstream Timeout] %s status: %d", apmType, statusCode)
+	default:
+		return NewUpstreamUnavailableError(nil, "[x Upstream Unavailable] %s status: %d", apmType, statusCode)
+	}
+}
+
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// GetErrorCode returns the ErrorCode carried by err, ErrCodeInternal is returned for untyped errors.
+func GetErrorCode(err error) ErrorCode {
+	var apmErr *ApmError
+	if errors.As(err, &apmErr) {
+		return apmErr.Code
+	}
+	return ErrCodeInternal
+}
@@ -0,0 +1,39 @@
+package apmapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGetErrorCode(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		expect ErrorCode
+	}{
+		{"untyped", errors.New("boom"), ErrCodeInternal},
+		{"notFound", NewNotFoundError("[x Trace NotFound] traceId: %s", "1"), ErrCodeNotFound},
+		{"wrapped", fmt.Errorf("search query error: %w", NewUnauthorizedError("401")), ErrCodeUnauthorized},
+		{"timeout", WrapRequestError("jaeger", context.DeadlineExceeded), ErrCodeTimeout},
+		{"unavailable", WrapRequestError("jaeger", errors.New("connection refused")), ErrCodeUpstreamUnavailable},
+		{"malformed", WrapDecodeError("jaeger", errors.New("unexpected end of JSON input")), ErrCodeMalformedResponse},
+		{"streamTimeout", WrapDecodeError("elastic", context.DeadlineExceeded), ErrCodeTimeout},
+		{"conversion", WrapConversionError("jaeger", errors.New("no span")), ErrCodeMalformedResponse},
+		{"typedConversion", WrapConversionError("jaeger", NewNotFoundError("[x Trace NotFound]")), ErrCodeNotFound},
+		{"status401", CheckResponseStatus("jaeger", 401), ErrCodeUnauthorized},
+		{"status504", CheckResponseStatus("jaeger", 504), ErrCodeTimeout},
+		{"status500", CheckResponseStatus("jaeger", 500), ErrCodeUpstreamUnavailable},
+		{"badRequest", WrapBadRequest(errors.New("invalid json")), ErrCodeBadRequest},
+	}
+	for _, c := range cases {
+		if got := GetErrorCode(c.err); got != c.expect {
+			t.Errorf("[Check %s] want=%s, got=%s", c.name, c.expect, got)
+		}
+	}
+
+	if CheckResponseStatus("jaeger", 200) != nil {
+		t.Errorf("[Check status200] want=nil")
+	}
+}
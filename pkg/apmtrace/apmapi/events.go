@@ -0,0 +1,66 @@
+package apmapi
+
+import (
+	"encoding/json"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// AttributeSpanEvents carries the span logs which are not exceptions, eg. the business events recorded by the application
+// and the annotations of the agents. The span model has no events, so they are encoded as a json array of SpanEvent.
+const AttributeSpanEvents = "apo.span.events"
+
+const (
+	// eventNameKey names the log in the OpenTracing convention, eg. event=redis.encode.start
+	eventNameKey     = "event"
+	defaultEventName = "log"
+)
+
+// SpanEvent is a log recorded on a span, Timestamp is in microseconds like the timestamp of the exceptions.
+type SpanEvent struct {
+	Timestamp  uint64            `json:"timestamp"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// NewSpanEvent names the event by the event field of the log, the other fields are the attributes.
+func NewSpanEvent(timestamp uint64, fields map[string]string) *SpanEvent {
+	name := fields[eventNameKey]
+	if name == "" {
+		name = defaultEventName
+	}
+	delete(fields, eventNameKey)
+	if len(fields) == 0 {
+		fields = nil
+	}
+	return &SpanEvent{
+		Timestamp:  timestamp,
+		Name:       name,
+		Attributes: fields,
+	}
+}
+
+// SetSpanEvents encodes events to AttributeSpanEvents of span, the attribute is not set if events is empty.
+func SetSpanEvents(span *model.OtelSpan, events []*SpanEvent) {
+	if len(events) == 0 {
+		return
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return
+	}
+	span.AddAttribute(AttributeSpanEvents, string(data))
+}
+
+// GetSpanEvents decodes AttributeSpanEvents of span, nil is returned if the span has no events.
+func GetSpanEvents(span *model.OtelSpan) ([]*SpanEvent, error) {
+	data, exist := span.Attributes[AttributeSpanEvents]
+	if !exist {
+		return nil, nil
+	}
+	var events []*SpanEvent
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,32 @@
+package apmapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestSpanEvents(t *testing.T) {
+	span := model.NewOtelSpan()
+	SetSpanEvents(span, nil)
+	if _, exist := span.Attributes[AttributeSpanEvents]; exist {
+		t.Errorf("[Check no events] want unset, got=%s", span.Attributes[AttributeSpanEvents])
+	}
+
+	expect := []*SpanEvent{
+		{Timestamp: 1000, Name: "order.created", Attributes: map[string]string{"order.id": "1"}},
+		{Timestamp: 2000, Name: "log"},
+	}
+	SetSpanEvents(span, []*SpanEvent{
+		NewSpanEvent(1000, map[string]string{"event": "order.created", "order.id": "1"}),
+		NewSpanEvent(2000, map[string]string{}),
+	})
+	got, err := GetSpanEvents(span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("[Check events] want=%+v, got=%+v", expect, got)
+	}
+}
@@ -0,0 +1,145 @@
+package golden
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+	cmodel "github.com/CloudDetail/apo-module/model/v1"
+)
+
+// DiffTraceCase returns one line per difference, prefixed by the path in the service tree,
+// eg. services[0].children[1].exitSpans[0].attributes["db.statement"]: want="...", got="..."
+func DiffTraceCase(expect *TraceCase, got *TraceCase) []string {
+	d := &differ{}
+	d.string("traceId", expect.TraceId, got.TraceId)
+	d.services("services", expect.Services, got.Services)
+	return d.diffs
+}
+
+// DiffServices returns the differences of two service trees.
+func DiffServices(expect []*model.OtelServiceNode, got []*model.OtelServiceNode) []string {
+	d := &differ{}
+	d.services("services", expect, got)
+	return d.diffs
+}
+
+type differ struct {
+	diffs []string
+}
+
+func (d *differ) add(path string, format string, args ...any) {
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *differ) string(path string, expect string, got string) {
+	if expect != got {
+		d.add(path, "want=%q, got=%q", expect, got)
+	}
+}
+
+func (d *differ) uint64(path string, expect uint64, got uint64) {
+	if expect != got {
+		d.add(path, "want=%d, got=%d", expect, got)
+	}
+}
+
+func (d *differ) services(path string, expect []*model.OtelServiceNode, got []*model.OtelServiceNode) {
+	for i := 0; i < len(expect) || i < len(got); i++ {
+		nodePath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(got):
+			d.add(nodePath, "missing service %q", getServiceName(expect[i]))
+		case i >= len(expect):
+			d.add(nodePath, "unexpected service %q", getServiceName(got[i]))
+		default:
+			d.service(nodePath, expect[i], got[i])
+		}
+	}
+}
+
+func (d *differ) service(path string, expect *model.OtelServiceNode, got *model.OtelServiceNode) {
+	d.spans(path+".entrySpans", expect.EntrySpans, got.EntrySpans)
+	d.spans(path+".exitSpans", expect.ExitSpans, got.ExitSpans)
+	d.spans(path+".errorSpans", expect.ErrorSpans, got.ErrorSpans)
+	d.services(path+".children", expect.Children, got.Children)
+}
+
+func (d *differ) spans(path string, expect []*model.OtelSpan, got []*model.OtelSpan) {
+	for i := 0; i < len(expect) || i < len(got); i++ {
+		spanPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(got):
+			d.add(spanPath, "missing span %q of %q", expect[i].Name, expect[i].ServiceName)
+		case i >= len(expect):
+			d.add(spanPath, "unexpected span %q of %q", got[i].Name, got[i].ServiceName)
+		default:
+			d.span(spanPath, expect[i], got[i])
+		}
+	}
+}
+
+func (d *differ) span(path string, expect *model.OtelSpan, got *model.OtelSpan) {
+	d.uint64(path+".startTime", expect.StartTime, got.StartTime)
+	d.uint64(path+".duration", expect.Duration, got.Duration)
+	d.string(path+".serviceName", expect.ServiceName, got.ServiceName)
+	d.string(path+".name", expect.Name, got.Name)
+	d.string(path+".spanId", expect.SpanId, got.SpanId)
+	d.string(path+".pSpanId", expect.PSpanId, got.PSpanId)
+	d.string(path+".nextSpanId", expect.NextSpanId, got.NextSpanId)
+	d.string(path+".kind", expect.Kind.String(), got.Kind.String())
+	d.string(path+".code", expect.Code.String(), got.Code.String())
+	d.attributes(path+".attributes", expect.Attributes, got.Attributes)
+	d.exceptions(path+".exceptions", expect.Exceptions, got.Exceptions)
+}
+
+func (d *differ) attributes(path string, expect map[string]string, got map[string]string) {
+	keys := make([]string, 0, len(expect)+len(got))
+	for key := range expect {
+		keys = append(keys, key)
+	}
+	for key := range got {
+		if _, exist := expect[key]; !exist {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		attrPath := fmt.Sprintf("%s[%q]", path, key)
+		expectValue, inExpect := expect[key]
+		gotValue, inGot := got[key]
+		switch {
+		case !inGot:
+			d.add(attrPath, "missing, want=%q", expectValue)
+		case !inExpect:
+			d.add(attrPath, "unexpected, got=%q", gotValue)
+		default:
+			d.string(attrPath, expectValue, gotValue)
+		}
+	}
+}
+
+func (d *differ) exceptions(path string, expect []*cmodel.Exception, got []*cmodel.Exception) {
+	for i := 0; i < len(expect) || i < len(got); i++ {
+		exceptionPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(got):
+			d.add(exceptionPath, "missing exception %q", expect[i].Type)
+		case i >= len(expect):
+			d.add(exceptionPath, "unexpected exception %q", got[i].Type)
+		default:
+			d.uint64(exceptionPath+".timestamp", expect[i].Timestamp, got[i].Timestamp)
+			d.string(exceptionPath+".type", expect[i].Type, got[i].Type)
+			d.string(exceptionPath+".message", expect[i].Message, got[i].Message)
+			d.string(exceptionPath+".stack", expect[i].Stack, got[i].Stack)
+		}
+	}
+}
+
+func getServiceName(node *model.OtelServiceNode) string {
+	if len(node.EntrySpans) > 0 {
+		return node.EntrySpans[0].ServiceName
+	}
+	return node.ServiceName
+}
@@ -0,0 +1,68 @@
+// Package golden converts the raw upstream responses of the trace cases and compares them with the expected service trees,
+// cases are laid out as <dir>/<apm>/<case>/data.json with the golden output in validate.json next to it.
+// The test runners are in apmtest, this package does not depend on testing.
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	DataFile     = "data.json"
+	ValidateFile = "validate.json"
+)
+
+// TraceCase is the content of validate.json.
+type TraceCase struct {
+	Name     string                   `json:"name"`
+	TraceId  string                   `json:"traceId"`
+	Services []*model.OtelServiceNode `json:"services"`
+}
+
+// ConvertTraceCase converts data.json of caseDir by backend, name is the name of the case in validate.json, eg. jaeger-http.
+func ConvertTraceCase(backend *apmapi.Backend, name string, caseDir string) (*TraceCase, error) {
+	data, err := os.ReadFile(filepath.Join(caseDir, DataFile))
+	if err != nil {
+		return nil, err
+	}
+	traceId, services, err := backend.ConvertFixture(data)
+	if err != nil {
+		return nil, fmt.Errorf("fail to convert to otel trace: %w", err)
+	}
+	return &TraceCase{
+		Name:     name,
+		TraceId:  traceId,
+		Services: services,
+	}, nil
+}
+
+// ReadTraceCase reads a golden validate.json.
+func ReadTraceCase(path string) (*TraceCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	traceCase := &TraceCase{}
+	if err = json.Unmarshal(data, traceCase); err != nil {
+		return nil, err
+	}
+	return traceCase, nil
+}
+
+// WriteTraceCase writes traceCase as the golden validate.json.
+func WriteTraceCase(path string, traceCase *TraceCase) error {
+	data, err := json.MarshalIndent(traceCase, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -3,38 +3,83 @@ package jaeger
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
 type JaegerApi struct {
-	Address string
-	Timeout time.Duration
+	Address        string
+	ServiceAddress string
+	Timeout        time.Duration
+	// Mapper maps the tags of the spans, DefaultAttributeMapping is used if it is nil.
+	Mapper *mapping.Mapper
 }
 
 func NewJaegerApi(address string, timeout int64) *JaegerApi {
 	return &JaegerApi{
-		Address: fmt.Sprintf("http://%s/api/traces", address),
-		Timeout: time.Duration(timeout) * time.Second,
+		Address:        fmt.Sprintf("http://%s/api/traces", address),
+		ServiceAddress: fmt.Sprintf("http://%s/api/services", address),
+		Timeout:        time.Duration(timeout) * time.Second,
 	}
 }
 
 func (jaeger *JaegerApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
-	resp, err := queryJson(fmt.Sprintf("%s/%s", jaeger.Address, traceId), jaeger.Timeout)
+	return jaeger.QueryListWithOptions(traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+}
+
+func (jaeger *JaegerApi) QueryListWithOptions(traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, error) {
+	data, err := jaeger.QueryRaw(traceId, startTimeMs, attributes)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	services, _, err := jaeger.ConvertRaw(traceId, data, opts)
+	return services, err
+}
+
+// ConvertRaw converts a response of QueryRaw with the mapper of the api, the trace is always complete.
+func (jaeger *JaegerApi) ConvertRaw(traceId string, data []byte, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
 	var response JaegerResponse
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, apmapi.WrapDecodeError("jaeger", err)
 	}
 	if len(response.Data) == 0 {
-		return nil, fmt.Errorf("[x Trace NotFound] Jaeger traceId: %s", traceId)
+		return nil, false, apmapi.NewNotFoundError("[x Trace NotFound] Jaeger traceId: %s", traceId)
+	}
+	services, err := ConvertToServiceNodes(&response.Data[0], jaeger.Mapper, opts.ClockSkew)
+	return services, true, err
+}
+
+func (jaeger *JaegerApi) QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error) {
+	resp, err := queryJson(fmt.Sprintf("%s/%s", jaeger.Address, traceId), jaeger.Timeout)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("jaeger", err)
 	}
-	return ConvertToServiceNodes(&response.Data[0])
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, apmapi.NewNotFoundError("[x Trace NotFound] Jaeger traceId: %s", traceId)
+	}
+	if err = apmapi.CheckResponseStatus("jaeger", resp.StatusCode); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("jaeger", err)
+	}
+	return data, nil
+}
+
+func (jaeger *JaegerApi) CheckHealth() error {
+	resp, err := queryJson(jaeger.ServiceAddress, jaeger.Timeout)
+	if err != nil {
+		return apmapi.WrapRequestError("jaeger", err)
+	}
+	defer resp.Body.Close()
+	return apmapi.CheckResponseStatus("jaeger", resp.StatusCode)
 }
 
 func queryJson(url string, timeout time.Duration) (*http.Response, error) {
@@ -0,0 +1,22 @@
+package jaeger_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+)
+
+func TestJaegerQueryList(t *testing.T) {
+	for _, testdata := range []string{"jaeger", "jaeger-1.32"} {
+		testdata := testdata
+		t.Run(testdata, func(t *testing.T) {
+			server := apmtest.NewJaegerServer()
+			defer server.Close()
+
+			api := jaeger.NewJaegerApi(server.Address(), 1)
+			apmtest.RunQueryTests(t, server, api, time.Second, "../testdata/tracelist/"+testdata)
+		})
+	}
+}
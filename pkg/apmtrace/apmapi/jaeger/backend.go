@@ -0,0 +1,71 @@
+package jaeger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	BackendName = "jaeger"
+	// ApmType is otel as the traces are exported to jaeger by OpenTelemetry.
+	ApmType = "otel"
+)
+
+type Config struct {
+	Address string `mapstructure:"address"`
+	// AttributeMapping is tried before DefaultAttributeMapping, eg. to map the tags of a custom instrumentation.
+	AttributeMapping []mapping.Rule `mapstructure:"attribute_mapping"`
+}
+
+func (conf *Config) Validate(prefix string) error {
+	return errors.Join(
+		config.ValidateHostAddress(prefix+".address", conf.Address),
+		mapping.Validate(prefix+".attribute_mapping", conf.AttributeMapping),
+	)
+}
+
+func init() {
+	apmapi.Register(&apmapi.Backend{
+		Name:    BackendName,
+		ApmType: ApmType,
+		NewConfig: func() any {
+			return &Config{}
+		},
+		NewApi: func(conf any, timeout int64) (apmapi.QueryByApmApi, error) {
+			jaegerConf := conf.(*Config)
+			if len(jaegerConf.Address) == 0 {
+				return nil, errors.New("jaeger.address is not set")
+			}
+			mapper, err := mapping.New(jaegerConf.AttributeMapping, DefaultAttributeMapping)
+			if err != nil {
+				return nil, fmt.Errorf("jaeger.attribute_mapping is invalid: %w", err)
+			}
+			api := NewJaegerApi(jaegerConf.Address, timeout)
+			api.Mapper = mapper
+			return api, nil
+		},
+		ConvertFixture: convertFixture,
+	})
+}
+
+func convertFixture(data []byte) (string, []*model.OtelServiceNode, error) {
+	response := &JaegerResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return "", nil, err
+	}
+	if len(response.Data) == 0 {
+		return "", nil, errors.New("no jaeger trace is found")
+	}
+
+	services, err := ConvertToServiceNodes(&response.Data[0], nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return response.Data[0].TraceId, services, nil
+}
@@ -0,0 +1,94 @@
+package jaeger
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+func BenchmarkConvertFixture(b *testing.B) {
+	apmtest.RunConvertBenchmarks(b, generateFanOutTrace, func(data []byte) error {
+		_, _, err := convertFixture(data)
+		return err
+	})
+}
+
+// generateFanOutTrace returns a trace of one producer publishing (spans-1)/2 messages, each consumed by one of the consumer services.
+func generateFanOutTrace(spans int) []byte {
+	const traceId = "0af7651916cd43dd8448eb211c80319c"
+	messages := (spans - 1) / 2
+	startTime := uint64(1706168391553000)
+	processes := map[string]*JaegerProcess{
+		"p0": newBenchProcess("kafka-producer"),
+	}
+	for i := 0; i < apmtest.FanOutConsumerServices; i++ {
+		processes[fmt.Sprintf("p%d", i+1)] = newBenchProcess(apmtest.FanOutConsumer(i))
+	}
+
+	jSpans := make([]*JaegerSpan, 0, 1+2*messages)
+	jSpans = append(jSpans, newBenchSpan(traceId, "r", "", "p0", "GET /orders/publish", "server", startTime, uint64(messages)))
+	for i := 1; i <= messages; i++ {
+		producerId := fmt.Sprintf("p%015x", i)
+		jSpans = append(jSpans, newBenchSpan(traceId, producerId, "r", "p0", "orders publish", "producer", startTime+uint64(i), 10))
+		consumer := newBenchSpan(traceId, fmt.Sprintf("c%015x", i), producerId, fmt.Sprintf("p%d", i%apmtest.FanOutConsumerServices+1), "orders process", "consumer", startTime+uint64(i)+20, 30)
+		jSpans = append(jSpans, consumer)
+	}
+	data, _ := json.Marshal(&JaegerResponse{
+		Data: []JaegerData{{
+			TraceId:   traceId,
+			Spans:     jSpans,
+			Processes: processes,
+		}},
+	})
+	return data
+}
+
+func newBenchProcess(serviceName string) *JaegerProcess {
+	return &JaegerProcess{
+		ServiceName: serviceName,
+		Tags: []*JaegerKeyValue{
+			{Key: "host.name", Type: "string", Value: serviceName + "-7d9f8b6c5-x2x4z"},
+			{Key: "telemetry.sdk.language", Type: "string", Value: "java"},
+		},
+	}
+}
+
+func newBenchSpan(traceId string, spanId string, parentId string, processId string, operation string, kind string, startTime uint64, duration uint64) *JaegerSpan {
+	span := &JaegerSpan{
+		TraceId:       traceId,
+		SpanId:        spanId,
+		OperationName: operation,
+		StartTime:     startTime,
+		Duration:      duration,
+		ProcessID:     processId,
+		Tags: []*JaegerKeyValue{
+			{Key: "span.kind", Type: "string", Value: kind},
+			{Key: "messaging.system", Type: "string", Value: "kafka"},
+			{Key: "messaging.destination.name", Type: "string", Value: "orders"},
+			{Key: "messaging.kafka.partition", Type: "int64", Value: 3},
+			{Key: "messaging.kafka.message.offset", Type: "int64", Value: startTime},
+			{Key: "messaging.kafka.consumer.group", Type: "string", Value: "orders-group"},
+			{Key: "messaging.client_id", Type: "string", Value: "consumer-orders-group-1"},
+			{Key: "messaging.operation", Type: "string", Value: "process"},
+			{Key: "messaging.message.payload_size_bytes", Type: "int64", Value: 512},
+			{Key: "net.peer.name", Type: "string", Value: "kafka"},
+			{Key: "net.peer.port", Type: "int64", Value: 9092},
+			{Key: "kafka.record.queue_time_ms", Type: "int64", Value: 2},
+			{Key: "otel.library.name", Type: "string", Value: "io.opentelemetry.kafka-clients-0.11"},
+			{Key: "thread.id", Type: "int64", Value: 42},
+			{Key: "thread.name", Type: "string", Value: "kafka-producer-network-thread"},
+		},
+		Logs: []*JaegerLog{{
+			Timestamp: startTime,
+			Fields: []*JaegerKeyValue{
+				{Key: "event", Type: "string", Value: "message sent"},
+			},
+		}},
+	}
+	if parentId != "" {
+		span.References = []*JaegerSpanRef{{RefType: "CHILD_OF", TraceId: traceId, SpanID: parentId}}
+	}
+	return span
+}
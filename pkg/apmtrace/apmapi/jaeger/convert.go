@@ -4,6 +4,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
@@ -13,42 +15,54 @@ var otSpanLogsMapping = map[string]string{
 	"stack":      model.AttributeExceptionStacktrace,
 }
 
-var otSpanTagsIgnoreMapping = map[string]bool{
-	"internal.span.format": true,
-	"otel.library.name":    true,
-	"otel.library.version": true,
-	"otel.scope.name":      true,
-	"otel.scope.version":   true,
-	"thread.id":            true,
-	"thread.name":          true,
-}
-
-func ConvertToServiceNodes(jaegerData *JaegerData) ([]*model.OtelServiceNode, error) {
+// ConvertToServiceNodes converts the spans, the tags are mapped by mapper or by DefaultAttributeMapping if mapper is nil.
+// The spans are adjusted by adjuster before the relations are built unless it is nil.
+func ConvertToServiceNodes(jaegerData *JaegerData, mapper *mapping.Mapper, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	if mapper == nil {
+		mapper = defaultMapper
+	}
 	traceData := model.NewOTelTrace("otel")
 	if jaegerData.Spans == nil && len(jaegerData.Spans) == 0 || len(jaegerData.Processes) == 0 {
 		return traceData.GetServiceNodes(), nil
 	}
 
-	processServiceNameMap := make(map[string]string)
+	processMap := make(map[string]*JaegerProcess, len(jaegerData.Processes))
+	resourceMap := make(map[string]map[string]string, len(jaegerData.Processes))
 	for key, process := range jaegerData.Processes {
-		processServiceNameMap[key] = process.ServiceName
+		if process != nil {
+			processMap[key] = process
+			resourceMap[key] = jProcessToResource(process)
+		}
 	}
 
 	traceTree := model.NewOtelTree()
 	for _, span := range jaegerData.Spans {
-		serviceName := processServiceNameMap[span.ProcessID]
-		if err := traceTree.AddSpan(jSpanToInternal(span, serviceName)); err != nil {
+		if span == nil {
+			return nil, apmapi.NewMalformedResponseError("[x Malformed Span] Jaeger span is null")
+		}
+		serviceName := ""
+		if process := processMap[span.ProcessID]; process != nil {
+			serviceName = process.ServiceName
+		}
+		if err := traceTree.AddSpan(jSpanToInternal(span, serviceName, resourceMap[span.ProcessID], mapper)); err != nil {
 			return nil, err
 		}
 	}
+	adjuster.Adjust(traceTree)
 	if err := traceTree.BuildRelation4Spans(traceData); err != nil {
 		return nil, err
 	}
 	return traceData.GetServiceNodes(), nil
 }
 
-func jSpanToInternal(span *JaegerSpan, serviceName string) *model.OtelSpan {
-	dest := model.NewOtelSpan()
+func jSpanToInternal(span *JaegerSpan, serviceName string, resource map[string]string, mapper *mapping.Mapper) *model.OtelSpan {
+	// The kind is read first as the mapping rules may be limited to span kinds.
+	kind := jTagsToSpanKind(span.Tags)
+	// The attributes are presized for the kept tags and the original spanId, instead of growing from empty span by span.
+	dest := &model.OtelSpan{
+		Attributes: make(map[string]string, countKeptTags(span.Tags, mapper, kind)+len(resource)+2),
+	}
+	dest.SetKind(kind)
 	dest.SetSpanId(span.SpanId)
 	dest.SetOriginalSpanId("OTEL", span.SpanId)
 	dest.SetServiceName(serviceName)
@@ -60,31 +74,66 @@ func jSpanToInternal(span *JaegerSpan, serviceName string) *model.OtelSpan {
 	if len(parentSpanID) > 0 {
 		dest.SetParentSpanId(parentSpanID)
 	}
-	jTagsToInternalAttributes(span.Tags, dest.Attributes)
-
-	if spanKindAttr, ok := dest.Attributes[TagSpanKind]; ok {
-		dest.SetKind(jSpanKindToInternal(spanKindAttr))
-		delete(dest.Attributes, TagSpanKind)
-	}
+	jTagsToInternalAttributes(span.Tags, dest.Attributes, mapper, kind)
+	delete(dest.Attributes, TagSpanKind)
+	apmapi.SetResourceAttributes(dest.Attributes, resource)
 	if _, ok := dest.Attributes["sw8.segment_id"]; ok && span.OperationName == "UndertowDispatch" {
 		// FIX Mismatch SpanId for UndertowDispatch.
 		dest.SetKind(model.SpanKindServer)
 	}
 	setInternalSpanStatus(dest.Attributes, dest)
 
-	jLogsToSpanExceptions(span.Logs, dest)
+	jLogsToSpanEvents(span.Logs, dest)
 
 	return dest
 }
 
-func jTagsToInternalAttributes(tags []*JaegerKeyValue, dest map[string]string) {
+func jTagsToInternalAttributes(tags []*JaegerKeyValue, dest map[string]string, mapper *mapping.Mapper, kind model.OtelSpanKind) {
 	for _, tag := range tags {
-		if _, exist := otSpanTagsIgnoreMapping[tag.Key]; !exist {
-			dest[tag.Key] = getTagStrValue(tag)
+		if tag == nil {
+			continue
 		}
+		mapper.Map(dest, tag.Key, getTagStrValue(tag), kind)
 	}
 }
 
+// jProcessToResource keeps apmapi.ResourceAttributes of the process tags, hostname is reported by the Jaeger clients.
+func jProcessToResource(process *JaegerProcess) map[string]string {
+	resource := make(map[string]string)
+	for _, tag := range process.Tags {
+		if tag == nil {
+			continue
+		}
+		if apmapi.ResourceAttributes[tag.Key] {
+			resource[tag.Key] = getTagStrValue(tag)
+		} else if tag.Key == TagHostname {
+			if _, exist := resource[apmapi.AttributeHostName]; !exist {
+				resource[apmapi.AttributeHostName] = getTagStrValue(tag)
+			}
+		}
+	}
+	return resource
+}
+
+func jTagsToSpanKind(tags []*JaegerKeyValue) model.OtelSpanKind {
+	for _, tag := range tags {
+		if tag != nil && tag.Key == TagSpanKind {
+			return jSpanKindToInternal(getTagStrValue(tag))
+		}
+	}
+	return model.SpanKindUnspecified
+}
+
+func countKeptTags(tags []*JaegerKeyValue, mapper *mapping.Mapper, kind model.OtelSpanKind) int {
+	count := 0
+	for _, tag := range tags {
+		if tag != nil && mapper.Keeps(tag.Key, kind) {
+			count++
+		}
+	}
+	return count
+}
+
 func getTagStrValue(tag *JaegerKeyValue) string {
 	switch tag.Type {
 	case "string":
@@ -101,20 +150,27 @@ func getTagStrValue(tag *JaegerKeyValue) string {
 	}
 }
 
-func jTagsToInternalLogAttributes(tags []*JaegerKeyValue, dest map[string]string) {
-	if len(tags) == 0 {
-		return
-	}
-
-	for _, tag := range tags {
-		otKey, ok := otSpanLogsMapping[tag.Key]
-		if ok {
-			// Replace Tag to OTel Tag
-			dest[otKey] = getTagStrValue(tag)
-		} else {
-			dest[tag.Key] = getTagStrValue(tag)
+// jLogToException reads the exception fields of a log, the keys are mapped by otSpanLogsMapping and the last value wins.
+func jLogToException(fields []*JaegerKeyValue) (exceptionType string, message string, stack string, isException bool) {
+	for _, field := range fields {
+		if field == nil {
+			continue
+		}
+		key := field.Key
+		if otKey, ok := otSpanLogsMapping[key]; ok {
+			key = otKey
+		}
+		switch key {
+		case model.AttributeExceptionType:
+			exceptionType = getTagStrValue(field)
+			isException = true
+		case model.AttributeExceptionMessage:
+			message = getTagStrValue(field)
+		case model.AttributeExceptionStacktrace:
+			stack = getTagStrValue(field)
 		}
 	}
+	return exceptionType, message, stack, isException
 }
 
 func setInternalSpanStatus(attrs map[string]string, span *model.OtelSpan) {
@@ -196,23 +252,28 @@ func jSpanKindToInternal(spanKind string) model.OtelSpanKind {
 	return model.SpanKindUnspecified
 }
 
-func jLogsToSpanExceptions(logs []*JaegerLog, dest *model.OtelSpan) {
+// jLogsToSpanEvents converts the logs with exception.type or error.kind to exceptions, the other logs are kept as span events.
+func jLogsToSpanEvents(logs []*JaegerLog, dest *model.OtelSpan) {
 	if len(logs) == 0 {
 		return
 	}
 
+	events := make([]*apmapi.SpanEvent, 0)
 	for _, log := range logs {
-		if len(log.Fields) > 0 {
-			attributes := make(map[string]string)
-			jTagsToInternalLogAttributes(log.Fields, attributes)
-
-			exceptionType, exist := attributes[model.AttributeExceptionType]
-			if exist {
-				message := attributes[model.AttributeExceptionMessage]
-				stack := attributes[model.AttributeExceptionStacktrace]
+		if log != nil && len(log.Fields) > 0 {
+			if exceptionType, message, stack, isException := jLogToException(log.Fields); isException {
 				// us
 				dest.AddException(log.Timestamp, exceptionType, message, stack)
+				continue
+			}
+			fields := make(map[string]string, len(log.Fields))
+			for _, field := range log.Fields {
+				if field != nil {
+					fields[field.Key] = getTagStrValue(field)
+				}
 			}
+			events = append(events, apmapi.NewSpanEvent(log.Timestamp, fields))
 		}
 	}
+	apmapi.SetSpanEvents(dest, events)
 }
@@ -0,0 +1,16 @@
+package jaeger
+
+import (
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+// FuzzConvertFixture checks that a malformed response is reported as error instead of panic, eg. go test -fuzz FuzzConvertFixture
+func FuzzConvertFixture(f *testing.F) {
+	apmtest.AddFuzzSeeds(f, "../testdata/tracelist/jaeger")
+	apmtest.AddFuzzSeeds(f, "../testdata/tracelist/jaeger-1.32")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = convertFixture(data)
+	})
+}
@@ -0,0 +1,18 @@
+package jaeger
+
+import (
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+)
+
+// DefaultAttributeMapping drops the tags of the instrumentation library and the thread, the rules in attribute_mapping are tried before them.
+var DefaultAttributeMapping = []mapping.Rule{
+	{Key: "internal.span.format", Action: mapping.ActionDrop},
+	{Key: "otel.library.name", Action: mapping.ActionDrop},
+	{Key: "otel.library.version", Action: mapping.ActionDrop},
+	{Key: "otel.scope.name", Action: mapping.ActionDrop},
+	{Key: "otel.scope.version", Action: mapping.ActionDrop},
+	{Key: "thread.id", Action: mapping.ActionDrop},
+	{Key: "thread.name", Action: mapping.ActionDrop},
+}
+
+var defaultMapper = mapping.MustNew(DefaultAttributeMapping)
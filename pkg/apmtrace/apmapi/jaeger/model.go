@@ -28,6 +28,9 @@ func (span *JaegerSpan) GetParentSpanID() string {
 	}
 	refSpanId := ""
 	for _, ref := range span.References {
+		if ref == nil {
+			continue
+		}
 		if ref.RefType == "CHILD_OF" {
 			return ref.SpanID
 		}
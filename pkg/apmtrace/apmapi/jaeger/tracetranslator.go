@@ -12,6 +12,9 @@ const (
 	TagW3CTraceState = "w3c.tracestate"
 
 	OtelStatusCode = "otel.status_code"
+
+	// TagHostname is the process tag of the Jaeger clients, the OpenTelemetry SDKs report host.name.
+	TagHostname = "hostname"
 )
 
 // Constants used for signifying batch-level attribute values where not supplied by OTLP data but required
@@ -0,0 +1,308 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	apmclient "github.com/CloudDetail/apo-module/apm/client/v1"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	// ActionRename sets the value to Target.
+	ActionRename = "rename"
+	// ActionDrop discards the tag.
+	ActionDrop = "drop"
+	// ActionKeep sets the tag as it is, it stops the rules listed after it.
+	ActionKeep = "keep"
+	// ActionValueMap sets the value translated by Values to Target, or to the key if Target is not set.
+	ActionValueMap = "value_map"
+	// ActionDerive sets the submatches of Pattern to Targets, the tag is then mapped by the following rules.
+	ActionDerive = "derive"
+
+	// ParserSQL derives the operation and table of a sql statement.
+	ParserSQL = "sql"
+)
+
+// Rule translates the tags of a backend to span attributes, it is listed in attribute_mapping of the backend section.
+// The rules are tried in order and the first rename, drop, keep or value_map rule matching Key ends the mapping of the tag,
+// derive rules only add attributes. Tags matching no rule are kept as they are.
+type Rule struct {
+	// Key is a glob of the tag key, * matches any characters, eg. db.*
+	Key string `mapstructure:"key"`
+	// SpanKinds limits the rule to the spans of the kinds, eg. client, internal.
+	SpanKinds []string `mapstructure:"span_kinds"`
+	Action    string   `mapstructure:"action"`
+	Target    string   `mapstructure:"target"`
+	// KeepExisting does not overwrite Target which is already set by another tag.
+	KeepExisting bool `mapstructure:"keep_existing"`
+	// Values translates the value case-insensitively, values not listed are kept. Lowercase lowers the result.
+	Values    map[string]string `mapstructure:"values"`
+	Lowercase bool              `mapstructure:"lowercase"`
+	// Pattern is matched against the value by derive, the submatches are set to Targets in order.
+	// Parser sql sets the operation and table of the statement to Targets instead.
+	Pattern string   `mapstructure:"pattern"`
+	Parser  string   `mapstructure:"parser"`
+	Targets []string `mapstructure:"targets"`
+}
+
+type compiledRule struct {
+	*Rule
+	// key is nil if Key has no wildcard, it is then compared as it is.
+	key     *regexp.Regexp
+	kinds   []model.OtelSpanKind
+	values  map[string]string
+	pattern *regexp.Regexp
+}
+
+// Mapper applies the compiled rules to the tags of a span.
+type Mapper struct {
+	rules []*compiledRule
+}
+
+// New compiles the rule lists in order, the configured rules are passed before the default rules of the backend.
+func New(ruleLists ...[]Rule) (*Mapper, error) {
+	mapper := &Mapper{}
+	errs := make([]error, 0)
+	index := 0
+	for _, rules := range ruleLists {
+		for i := range rules {
+			rule, err := compile(&rules[i])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("[%d].%w", index, err))
+			} else {
+				mapper.rules = append(mapper.rules, rule)
+			}
+			index++
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return mapper, nil
+}
+
+// MustNew is New for the default rules declared in code, it panics on an invalid rule.
+func MustNew(ruleLists ...[]Rule) *Mapper {
+	mapper, err := New(ruleLists...)
+	if err != nil {
+		panic(err)
+	}
+	return mapper
+}
+
+// Validate checks the configured rules, key is the config key of the list, eg. adapter.trace_api.skywalking.attribute_mapping.
+func Validate(key string, rules []Rule) error {
+	errs := make([]error, 0)
+	for i := range rules {
+		if _, err := compile(&rules[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d].%w", key, i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func compile(rule *Rule) (*compiledRule, error) {
+	if rule.Key == "" {
+		return nil, errors.New("key is required")
+	}
+	compiled := &compiledRule{
+		Rule: rule,
+	}
+	if strings.ContainsAny(rule.Key, "*?") {
+		compiled.key = compileGlob(rule.Key)
+	}
+	for _, name := range rule.SpanKinds {
+		kind, ok := parseSpanKind(name)
+		if !ok {
+			return nil, fmt.Errorf("span_kinds: unknown kind %s", name)
+		}
+		compiled.kinds = append(compiled.kinds, kind)
+	}
+
+	switch rule.Action {
+	case ActionRename:
+		if rule.Target == "" {
+			return nil, fmt.Errorf("target is required by %s", rule.Action)
+		}
+	case ActionDrop, ActionKeep:
+	case ActionValueMap:
+		if len(rule.Values) == 0 && !rule.Lowercase {
+			return nil, fmt.Errorf("values is required by %s", rule.Action)
+		}
+		// The keys are lowered by viper when the config is read.
+		compiled.values = make(map[string]string, len(rule.Values))
+		for from, to := range rule.Values {
+			compiled.values[strings.ToLower(from)] = to
+		}
+	case ActionDerive:
+		if len(rule.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required by %s", rule.Action)
+		}
+		switch {
+		case rule.Parser == ParserSQL:
+			if rule.Pattern != "" {
+				return nil, errors.New("pattern and parser are exclusive")
+			}
+		case rule.Parser != "":
+			return nil, fmt.Errorf("parser: unknown parser %s, supported: [%s]", rule.Parser, ParserSQL)
+		case rule.Pattern == "":
+			return nil, fmt.Errorf("pattern or parser is required by %s", rule.Action)
+		default:
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("pattern is invalid: %v", err)
+			}
+			if pattern.NumSubexp() < len(rule.Targets) {
+				return nil, fmt.Errorf("pattern has %d submatches for %d targets", pattern.NumSubexp(), len(rule.Targets))
+			}
+			compiled.pattern = pattern
+		}
+	case "":
+		return nil, errors.New("action is required")
+	default:
+		return nil, fmt.Errorf("action: unknown action %s, supported: [%s %s %s %s %s]",
+			rule.Action, ActionRename, ActionDrop, ActionKeep, ActionValueMap, ActionDerive)
+	}
+	return compiled, nil
+}
+
+// compileGlob translates the glob to a regexp, unlike path.Match * also matches / which appears in Pinpoint titles.
+func compileGlob(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+func parseSpanKind(name string) (model.OtelSpanKind, bool) {
+	for _, kind := range []model.OtelSpanKind{
+		model.SpanKindUnspecified,
+		model.SpanKindInternal,
+		model.SpanKindServer,
+		model.SpanKindClient,
+		model.SpanKindProducer,
+		model.SpanKindConsumer,
+	} {
+		if strings.EqualFold(kind.String(), name) {
+			return kind, true
+		}
+	}
+	return model.SpanKindUnspecified, false
+}
+
+func (rule *compiledRule) match(key string, kind model.OtelSpanKind) bool {
+	if len(rule.kinds) > 0 {
+		matched := false
+		for _, ruleKind := range rule.kinds {
+			if ruleKind == kind {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return rule.matchKey(key)
+}
+
+func (rule *compiledRule) matchKey(key string) bool {
+	if rule.key == nil {
+		return rule.Key == key
+	}
+	return rule.key.MatchString(key)
+}
+
+// Map sets the attributes translated from the tag into dest, kind is the kind of the span owning the tag.
+func (m *Mapper) Map(dest map[string]string, key string, value string, kind model.OtelSpanKind) {
+	for _, rule := range m.rules {
+		if !rule.match(key, kind) {
+			continue
+		}
+		switch rule.Action {
+		case ActionRename:
+			setAttribute(dest, rule.Target, value, rule.KeepExisting)
+		case ActionDrop:
+		case ActionKeep:
+			dest[key] = value
+		case ActionValueMap:
+			target := rule.Target
+			if target == "" {
+				target = key
+			}
+			setAttribute(dest, target, rule.mapValue(value), rule.KeepExisting)
+		case ActionDerive:
+			rule.derive(dest, value)
+			continue
+		}
+		return
+	}
+	dest[key] = value
+}
+
+// Keeps reports whether the tag sets any attribute, it is used to presize the attributes.
+func (m *Mapper) Keeps(key string, kind model.OtelSpanKind) bool {
+	for _, rule := range m.rules {
+		if rule.Action != ActionDerive && rule.match(key, kind) {
+			return rule.Action != ActionDrop
+		}
+	}
+	return true
+}
+
+// MapValue returns the value translated by the first value_map rule of key, it maps the fields which are not tags, eg. the component of SkyWalking.
+func (m *Mapper) MapValue(key string, value string) string {
+	for _, rule := range m.rules {
+		if rule.Action == ActionValueMap && rule.matchKey(key) {
+			return rule.mapValue(value)
+		}
+	}
+	return value
+}
+
+func (rule *compiledRule) mapValue(value string) string {
+	if mapped, ok := rule.values[strings.ToLower(value)]; ok {
+		value = mapped
+	}
+	if rule.Lowercase {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+func (rule *compiledRule) derive(dest map[string]string, value string) {
+	if value == "" {
+		return
+	}
+	if rule.Parser == ParserSQL {
+		operation, table := apmclient.SQLParseOperationAndTableNEW(value)
+		if operation == "" {
+			return
+		}
+		for i, derived := range []string{operation, table} {
+			if i < len(rule.Targets) {
+				setAttribute(dest, rule.Targets[i], derived, rule.KeepExisting)
+			}
+		}
+		return
+	}
+	submatches := rule.pattern.FindStringSubmatch(value)
+	if submatches == nil {
+		return
+	}
+	for i, target := range rule.Targets {
+		setAttribute(dest, target, submatches[i+1], rule.KeepExisting)
+	}
+}
+
+func setAttribute(dest map[string]string, key string, value string, keepExisting bool) {
+	if keepExisting {
+		if _, exist := dest[key]; exist {
+			return
+		}
+	}
+	dest[key] = value
+}
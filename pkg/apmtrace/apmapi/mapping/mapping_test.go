@@ -0,0 +1,118 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestMap(t *testing.T) {
+	configured := []Rule{
+		{Key: "plugin.sql", Action: ActionRename, Target: model.AttributeDBStatement},
+		{Key: "plugin.route", Action: ActionDerive, Pattern: `^(\w+) (/\S*)`, Targets: []string{"http.method", "http.route"}},
+		{Key: "plugin.*", Action: ActionDrop},
+		{Key: "db.type", SpanKinds: []string{"internal"}, Action: ActionKeep},
+	}
+	defaults := []Rule{
+		{Key: "db.type", Action: ActionValueMap, Target: model.AttributeDBSystem, Values: map[string]string{"mysql-connector-java": "Mysql"}, Lowercase: true},
+		{Key: "db.statement", Action: ActionDerive, Parser: ParserSQL, Targets: []string{model.AttributeDBOperation, model.AttributeDBSQLTable}},
+		{Key: "mq.queue", Action: ActionRename, Target: model.AttributeMessageDestinationName},
+		{Key: "mq.topic", Action: ActionRename, Target: model.AttributeMessageDestinationName, KeepExisting: true},
+	}
+	mapper, err := New(configured, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		kind   model.OtelSpanKind
+		tags   [][2]string
+		expect map[string]string
+	}{
+		{
+			name: "rename",
+			kind: model.SpanKindClient,
+			tags: [][2]string{{"plugin.sql", "select * from orders"}, {"plugin.user", "admin"}},
+			expect: map[string]string{
+				model.AttributeDBStatement: "select * from orders",
+			},
+		},
+		{
+			name: "derive",
+			kind: model.SpanKindServer,
+			tags: [][2]string{{"plugin.route", "GET /orders/{id}"}, {"db.statement", "SELECT * FROM orders"}},
+			expect: map[string]string{
+				"http.method":              "GET",
+				"http.route":               "/orders/{id}",
+				"db.statement":             "SELECT * FROM orders",
+				model.AttributeDBOperation: "SELECT",
+				model.AttributeDBSQLTable:  "orders",
+			},
+		},
+		{
+			name:   "valueMap",
+			kind:   model.SpanKindClient,
+			tags:   [][2]string{{"db.type", "MySQL-Connector-Java"}, {"thread.name", "main"}},
+			expect: map[string]string{model.AttributeDBSystem: "mysql", "thread.name": "main"},
+		},
+		{
+			name:   "spanKinds",
+			kind:   model.SpanKindInternal,
+			tags:   [][2]string{{"db.type", "mysql-connector-java"}},
+			expect: map[string]string{"db.type": "mysql-connector-java"},
+		},
+		{
+			name:   "keepExisting",
+			kind:   model.SpanKindProducer,
+			tags:   [][2]string{{"mq.topic", "orders"}, {"mq.queue", "orders-1"}, {"mq.topic", "payments"}},
+			expect: map[string]string{model.AttributeMessageDestinationName: "orders-1"},
+		},
+	}
+	for _, test := range tests {
+		got := make(map[string]string)
+		for _, tag := range test.tags {
+			mapper.Map(got, tag[0], tag[1], test.kind)
+		}
+		if !reflect.DeepEqual(test.expect, got) {
+			t.Errorf("[Check %s] want=%v, got=%v", test.name, test.expect, got)
+		}
+	}
+
+	if mapper.Keeps("plugin.user", model.SpanKindClient) {
+		t.Errorf("[Check Keeps] want=false for dropped plugin.user")
+	}
+	if got := mapper.MapValue("db.type", "Unknown-Driver"); got != "unknown-driver" {
+		t.Errorf("[Check MapValue] want=unknown-driver, got=%s", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate("attribute_mapping", []Rule{
+		{Key: "a", Action: ActionRename},
+		{Key: "b", Action: "move"},
+		{Key: "c", Action: ActionDerive, Pattern: `(\d+)`, Targets: []string{"x", "y"}},
+		{Key: "d", Action: ActionDrop, SpanKinds: []string{"entry"}},
+		{Action: ActionDrop},
+		{Key: "e/*", Action: ActionDrop},
+	})
+	if err == nil {
+		t.Fatal("[Check invalid rules] want error, got nil")
+	}
+	for _, expect := range []string{
+		"attribute_mapping[0].target is required by rename",
+		"attribute_mapping[1].action: unknown action move",
+		"attribute_mapping[2].pattern has 1 submatches for 2 targets",
+		"attribute_mapping[3].span_kinds: unknown kind entry",
+		"attribute_mapping[4].key is required",
+	} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("[Check invalid rules] want=%s, got=%v", expect, err)
+		}
+	}
+	if strings.Contains(err.Error(), "[5]") {
+		t.Errorf("[Check glob with /] want valid, got=%v", err)
+	}
+}
@@ -3,42 +3,97 @@ package pinpoint
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
 type PinpointApi struct {
-	Address string
-	Timeout time.Duration
+	Address       string
+	ServerAddress string
+	Timeout       time.Duration
+	// Mapper maps the annotations of the callStack, DefaultAttributeMapping is used if it is nil.
+	Mapper *mapping.Mapper
+	// Strict returns the Incomplete error for an incomplete trace, the received callStack is converted otherwise.
+	Strict bool
 }
 
 func NewPinpointApi(address string, timeout int64) (ppApi *PinpointApi, err error) {
 	return &PinpointApi{
-		Address: fmt.Sprintf("http://%s/transactionInfo.pinpoint", address),
-		Timeout: time.Duration(timeout) * time.Second,
+		Address:       fmt.Sprintf("http://%s/transactionInfo.pinpoint", address),
+		ServerAddress: fmt.Sprintf("http://%s/serverTime.pinpoint", address),
+		Timeout:       time.Duration(timeout) * time.Second,
 	}, nil
 }
 
 func (pinpoint *PinpointApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
+	services, _, err := pinpoint.QueryPartialList(traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+	return services, err
+}
+
+// QueryPartialList converts the received callStack of an incomplete trace unless the query is strict,
+// the subtrees whose parent is not received are returned as other root services.
+func (pinpoint *PinpointApi) QueryPartialList(traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	data, err := pinpoint.QueryRaw(traceId, startTimeMs, attributes)
+	if err != nil {
+		return nil, false, err
+	}
+	return pinpoint.ConvertRaw(traceId, data, opts)
+}
+
+// ConvertRaw converts a response of QueryRaw with the mapper and the strict config of the api.
+func (pinpoint *PinpointApi) ConvertRaw(traceId string, data []byte, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	var response PinpointResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, apmapi.WrapDecodeError("pinpoint", err)
+	}
+	if response.Exception != nil {
+		return nil, false, apmapi.NewNotFoundError("[x Trace NotFound] Pinpoint traceId: %s", traceId)
+	}
+	if response.IsComplete() {
+		services, err := response.ConvertToServiceNodes(pinpoint.Mapper, opts.ClockSkew)
+		return services, true, err
+	}
+
+	strict := pinpoint.Strict
+	if opts.Strict != nil {
+		strict = *opts.Strict
+	}
+	if strict {
+		return nil, false, apmapi.NewIncompleteError("[x Trace NotComplete] Pinpoint traceId: %s, state: %s", traceId, response.Complete)
+	}
+	services, err := response.ConvertPartialServiceNodes(pinpoint.Mapper, opts.ClockSkew)
+	return services, false, err
+}
+
+func (pinpoint *PinpointApi) QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error) {
 	resp, err := queryJson(fmt.Sprintf("%s?traceId=%s", pinpoint.Address, strings.ReplaceAll(traceId, "^", "%5E")), pinpoint.Timeout)
 	if err != nil {
-		return nil, err
+		return nil, apmapi.WrapRequestError("pinpoint", err)
 	}
 	defer resp.Body.Close()
-	var response PinpointResponse
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err = apmapi.CheckResponseStatus("pinpoint", resp.StatusCode); err != nil {
 		return nil, err
 	}
-	if response.Exception != nil {
-		return nil, fmt.Errorf("[x Trace NotFound] Pinpoint traceId: %s", traceId)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("pinpoint", err)
 	}
-	if response.Complete != "Complete" {
-		return nil, fmt.Errorf("[x Trace NotComplete] Pinpoint traceId: %s", traceId)
+	return data, nil
+}
+
+func (pinpoint *PinpointApi) CheckHealth() error {
+	resp, err := queryJson(pinpoint.ServerAddress, pinpoint.Timeout)
+	if err != nil {
+		return apmapi.WrapRequestError("pinpoint", err)
 	}
-	return response.ConvertToServiceNodes()
+	defer resp.Body.Close()
+	return apmapi.CheckResponseStatus("pinpoint", resp.StatusCode)
 }
 
 func queryJson(url string, timeout time.Duration) (*http.Response, error) {
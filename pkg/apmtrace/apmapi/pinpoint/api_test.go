@@ -0,0 +1,59 @@
+package pinpoint_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/pinpoint"
+)
+
+// pinpoint is captured from pinpoint-web 2.x. pinpoint-reordered holds the same transactions with the columns of
+// callStackIndex sorted by name, it checks that the rows are read by the names and is not a capture of another release.
+func TestPinpointQueryList(t *testing.T) {
+	for _, testdata := range []string{"pinpoint", "pinpoint-reordered"} {
+		testdata := testdata
+		t.Run(testdata, func(t *testing.T) {
+			server := apmtest.NewPinpointServer()
+			defer server.Close()
+
+			api, err := pinpoint.NewPinpointApi(server.Address(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			apmtest.RunQueryTests(t, server, api, time.Second, "../testdata/tracelist/"+testdata)
+		})
+	}
+}
+
+func TestPinpointStrict(t *testing.T) {
+	server := apmtest.NewPinpointServer()
+	defer server.Close()
+	if _, err := server.AddTestdata("../testdata/tracelist/pinpoint"); err != nil {
+		t.Fatal(err)
+	}
+
+	api, err := pinpoint.NewPinpointApi(server.Address(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incompleteTraceId := "dubbo-consumer^1718250796910^2"
+	_, complete, err := api.QueryPartialList(incompleteTraceId, 0, "", apmapi.QueryOptions{})
+	if err != nil || complete {
+		t.Errorf("[Check partial] want complete=false and nil error, got complete=%v, err=%v", complete, err)
+	}
+
+	strict := true
+	_, _, err = api.QueryPartialList(incompleteTraceId, 0, "", apmapi.QueryOptions{Strict: &strict})
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeIncomplete {
+		t.Errorf("[Check strict request] want=%s, got=%s (%v)", apmapi.ErrCodeIncomplete, got, err)
+	}
+
+	api.Strict = true
+	apmtest.CheckErrorCode(t, api, incompleteTraceId, apmapi.ErrCodeIncomplete)
+	notStrict := false
+	if _, _, err = api.QueryPartialList(incompleteTraceId, 0, "", apmapi.QueryOptions{Strict: &notStrict}); err != nil {
+		t.Errorf("[Check request overrides strict config] want=nil, got=%v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package pinpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	BackendName = "pinpoint"
+	ApmType     = "pinpoint"
+)
+
+type Config struct {
+	Address string `mapstructure:"address"`
+	// AttributeMapping is tried before DefaultAttributeMapping, the keys are the titles of the annotations, eg. SQL.
+	AttributeMapping []mapping.Rule `mapstructure:"attribute_mapping"`
+	// Strict returns TRACE_INCOMPLETE for the traces which are not complete instead of converting the received callStack.
+	// It is false by default, the earlier releases always returned the error as strict does.
+	Strict bool `mapstructure:"strict"`
+}
+
+func (conf *Config) Validate(prefix string) error {
+	return errors.Join(
+		config.ValidateHostAddress(prefix+".address", conf.Address),
+		mapping.Validate(prefix+".attribute_mapping", conf.AttributeMapping),
+	)
+}
+
+func init() {
+	apmapi.Register(&apmapi.Backend{
+		Name:    BackendName,
+		ApmType: ApmType,
+		NewConfig: func() any {
+			return &Config{}
+		},
+		NewApi: func(conf any, timeout int64) (apmapi.QueryByApmApi, error) {
+			ppConf := conf.(*Config)
+			if len(ppConf.Address) == 0 {
+				return nil, errors.New("pinpoint.address is not set")
+			}
+			mapper, err := mapping.New(ppConf.AttributeMapping, DefaultAttributeMapping)
+			if err != nil {
+				return nil, fmt.Errorf("pinpoint.attribute_mapping is invalid: %w", err)
+			}
+			api, err := NewPinpointApi(ppConf.Address, timeout)
+			if err != nil {
+				return nil, err
+			}
+			api.Mapper = mapper
+			api.Strict = ppConf.Strict
+			return api, nil
+		},
+		ConvertFixture: convertFixture,
+	})
+}
+
+// convertFixture converts an incomplete trace partially regardless of strict, the strict config and request field
+// are applied by PinpointApi.ConvertRaw before a query is recorded.
+func convertFixture(data []byte) (string, []*model.OtelServiceNode, error) {
+	response := &PinpointResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return "", nil, err
+	}
+
+	var services []*model.OtelServiceNode
+	var err error
+	if response.IsComplete() {
+		services, err = response.ConvertToServiceNodes(nil, nil)
+	} else {
+		services, err = response.ConvertPartialServiceNodes(nil, nil)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return response.TraceId, services, nil
+}
@@ -0,0 +1,56 @@
+package pinpoint
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+func BenchmarkConvertFixture(b *testing.B) {
+	apmtest.RunConvertBenchmarks(b, generateFanOutTrace, func(data []byte) error {
+		_, _, err := convertFixture(data)
+		return err
+	})
+}
+
+// generateFanOutTrace returns a callStack of one servlet sending (spans-1)/3 messages, each consumed by a listener of one of the consumer services.
+func generateFanOutTrace(spans int) []byte {
+	messages := (spans - 1) / 3
+	begin := int64(1718250906685)
+	callStacks := make([][]any, 0, 2+3*messages)
+	callStacks = append(callStacks,
+		newBenchRow(1, "", "kafka-producer", begin, begin+int64(messages)+100, true, true, "Servlet Process", "/orders/publish", "", "TOMCAT"),
+		newBenchRow(2, "1", "", 0, 0, false, false, "http.status.code", "200", "", ""),
+	)
+	for i := 0; i < messages; i++ {
+		clientId := strconv.Itoa(3 + 3*i)
+		callStacks = append(callStacks,
+			newBenchRow(3+3*i, "1", "kafka-producer", begin+int64(i), begin+int64(i)+2, true, false, "send(ProducerRecord record)", "kafka:9092/orders", "KafkaProducer", "KAFKA_CLIENT"),
+			newBenchRow(4+3*i, clientId, apmtest.FanOutConsumer(i), begin+int64(i)+3, begin+int64(i)+8, true, true, "Kafka Consumer Invocation", "kafka://topic=orders", "", "KAFKA_CLIENT_INTERNAL"),
+			newBenchRow(5+3*i, strconv.Itoa(4+3*i), apmtest.FanOutConsumer(i), begin+int64(i)+4, begin+int64(i)+7, true, false, "consume(ConsumerRecord record)", "", "OrderListener", "SPRING_BEAN"),
+		)
+	}
+	data, _ := json.Marshal(map[string]any{
+		"transactionId":  "kafka-producer^1718250906000^1",
+		"completeState":  "Complete",
+		"callStackIndex": benchCallStackIndex,
+		"callStack":      callStacks,
+	})
+	return data
+}
+
+// benchCallStackIndex is callStackIndex of pinpoint-web 2.x.
+var benchCallStackIndex = map[string]int{
+	"depth": 0, "begin": 1, "end": 2, "excludeFromTimeline": 3, "applicationName": 4, "tab": 5, "id": 6, "parentId": 7,
+	"isMethod": 8, "hasChild": 9, "title": 10, "arguments": 11, "executeTime": 12, "gap": 13, "elapsedTime": 14, "barWidth": 15,
+	"executionMilliseconds": 16, "simpleClassName": 17, "methodType": 18, "apiType": 19, "agent": 20, "isFocused": 21,
+	"hasException": 22, "isAuthorized": 23,
+}
+
+// newBenchRow lays out the columns as benchCallStackIndex.
+func newBenchRow(id int, parentId string, applicationName string, begin int64, end int64, isMethod bool, hasChild bool, title string, arguments string, simpleClassName string, apiType string) []any {
+	return []any{"", begin, end, false, applicationName, 0, strconv.Itoa(id), parentId, isMethod, hasChild, title, arguments,
+		"", "", "", "", "", simpleClassName, "0", apiType, applicationName, false, false, true}
+}
@@ -0,0 +1,199 @@
+package pinpoint
+
+import (
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+// Columns read from a callStack row, their indexes are looked up by name in callStackIndex of the response.
+const (
+	columnBegin = iota
+	columnEnd
+	columnApplicationName
+	columnId
+	columnParentId
+	columnIsMethod
+	columnHasChild
+	columnTitle
+	columnArguments
+	columnSimpleClassName
+	columnApiType
+	columnHasException
+
+	columnCount
+)
+
+var columnNames = [columnCount]string{
+	columnBegin:           "begin",
+	columnEnd:             "end",
+	columnApplicationName: "applicationName",
+	columnId:              "id",
+	columnParentId:        "parentId",
+	columnIsMethod:        "isMethod",
+	columnHasChild:        "hasChild",
+	columnTitle:           "title",
+	columnArguments:       "arguments",
+	columnSimpleClassName: "simpleClassName",
+	columnApiType:         "apiType",
+	columnHasException:    "hasException",
+}
+
+// defaultCallStackIndex is the layout of pinpoint-web 2.x, it is used if the response has no callStackIndex,
+// the version of the layout is defaultCallStackVersion then.
+var defaultCallStackIndex = map[string]int{
+	"begin":           1,
+	"end":             2,
+	"applicationName": 4,
+	"id":              6,
+	"parentId":        7,
+	"isMethod":        8,
+	"hasChild":        9,
+	"title":           10,
+	"arguments":       11,
+	"simpleClassName": 17,
+	"apiType":         19,
+	"hasException":    22,
+}
+
+// callStackVersions detects the release of pinpoint-web by a column added in it, the newest release is listed first.
+var callStackVersions = []struct {
+	version string
+	column  string
+}{
+	{"3.x", "agentName"},
+}
+
+const defaultCallStackVersion = "2.x"
+
+// callStackLayout locates the columns of the callStack rows, it is read from callStackIndex
+// since pinpoint-web adds and moves the columns between releases.
+type callStackLayout struct {
+	// version is the release of pinpoint-web detected from the columns, eg. 2.x
+	version    string
+	indexes    [columnCount]int
+	minColumns int
+}
+
+// newCallStackLayout reads callStackIndex, the layout of pinpoint-web 2.x is used if it is empty.
+// The detected release is reported with the missing columns, so a layout change of pinpoint-web is told from a broken response.
+func newCallStackLayout(callStackIndex map[string]int) (*callStackLayout, error) {
+	if len(callStackIndex) == 0 {
+		callStackIndex = defaultCallStackIndex
+	}
+	layout := &callStackLayout{
+		version: detectCallStackVersion(callStackIndex),
+	}
+	for column, name := range columnNames {
+		index, exist := callStackIndex[name]
+		if !exist {
+			return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] callStackIndex of pinpoint-web %s misses column %q", layout.version, name)
+		}
+		if index < 0 {
+			return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] callStackIndex of pinpoint-web %s has negative index %d of column %q", layout.version, index, name)
+		}
+		layout.indexes[column] = index
+		if index >= layout.minColumns {
+			layout.minColumns = index + 1
+		}
+	}
+	return layout, nil
+}
+
+func detectCallStackVersion(callStackIndex map[string]int) string {
+	for _, known := range callStackVersions {
+		if _, exist := callStackIndex[known.column]; exist {
+			return known.version
+		}
+	}
+	return defaultCallStackVersion
+}
+
+// CallStackRow is the typed view of a callStack row, null columns are read as zero values.
+type CallStackRow struct {
+	Begin           float64
+	End             float64
+	ApplicationName string
+	Id              string
+	ParentId        string
+	IsMethod        bool
+	HasChild        bool
+	Title           string
+	Arguments       string
+	SimpleClassName string
+	ApiType         string
+	HasException    bool
+}
+
+func parseCallStackRow(layout *callStackLayout, index int, values []interface{}) (*CallStackRow, error) {
+	if len(values) < layout.minColumns {
+		return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] row %d has %d columns, want at least %d", index, len(values), layout.minColumns)
+	}
+	parser := &rowParser{layout: layout, index: index, values: values}
+	row := &CallStackRow{
+		Begin:           parser.getFloat(columnBegin),
+		End:             parser.getFloat(columnEnd),
+		ApplicationName: parser.getString(columnApplicationName),
+		Id:              parser.getString(columnId),
+		ParentId:        parser.getString(columnParentId),
+		IsMethod:        parser.getBool(columnIsMethod),
+		HasChild:        parser.getBool(columnHasChild),
+		Title:           parser.getString(columnTitle),
+		Arguments:       parser.getString(columnArguments),
+		SimpleClassName: parser.getString(columnSimpleClassName),
+		ApiType:         parser.getString(columnApiType),
+		HasException:    parser.getBool(columnHasException),
+	}
+	if parser.err != nil {
+		return nil, parser.err
+	}
+	return row, nil
+}
+
+// rowParser keeps the first type mismatch, so the columns can be read without checking every one.
+type rowParser struct {
+	layout *callStackLayout
+	index  int
+	values []interface{}
+	err    error
+}
+
+func (p *rowParser) getString(column int) string {
+	value := p.values[p.layout.indexes[column]]
+	if value == nil {
+		return ""
+	}
+	if result, ok := value.(string); ok {
+		return result
+	}
+	p.setError(column, "string", value)
+	return ""
+}
+
+func (p *rowParser) getBool(column int) bool {
+	value := p.values[p.layout.indexes[column]]
+	if value == nil {
+		return false
+	}
+	if result, ok := value.(bool); ok {
+		return result
+	}
+	p.setError(column, "bool", value)
+	return false
+}
+
+func (p *rowParser) getFloat(column int) float64 {
+	value := p.values[p.layout.indexes[column]]
+	if value == nil {
+		return 0
+	}
+	if result, ok := value.(float64); ok && result >= 0 {
+		return result
+	}
+	p.setError(column, "non-negative number", value)
+	return 0
+}
+
+func (p *rowParser) setError(column int, expect string, value interface{}) {
+	if p.err == nil {
+		p.err = apmapi.NewMalformedResponseError("[x Malformed CallStack] row %d column %s want %s, got %T", p.index, columnNames[column], expect, value)
+	}
+}
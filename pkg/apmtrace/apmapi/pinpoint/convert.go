@@ -1,6 +1,11 @@
 package pinpoint
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
 
 var clientServiceTypes = []string{
 	"UNKNOWN_DB",
@@ -74,3 +79,70 @@ func IsClient(serviceType string) bool {
 	}
 	return false
 }
+
+// exitSystems maps the prefix of the serviceType of an exit span to the system attribute.
+var exitSystems = []struct {
+	prefix    string
+	attribute string
+	system    string
+}{
+	{"MYSQL", model.AttributeDBSystem, "mysql"},
+	{"MARIADB", model.AttributeDBSystem, "mariadb"},
+	{"MSSQL", model.AttributeDBSystem, "mssql"},
+	{"ORACLE", model.AttributeDBSystem, "oracle"},
+	{"CUBRID", model.AttributeDBSystem, "cubrid"},
+	{"INFORMIX", model.AttributeDBSystem, "informix"},
+	{"POSTGRESQL", model.AttributeDBSystem, "postgresql"},
+	{"CASSANDRA", model.AttributeDBSystem, "cassandra"},
+	{"MONGO", model.AttributeDBSystem, "mongodb"},
+	{"COUCHDB", model.AttributeDBSystem, "couchdb"},
+	{"H2", model.AttributeDBSystem, "h2"},
+	{"CLICK_HOUSE", model.AttributeDBSystem, "clickhouse"},
+	{"MEMCACHED", model.AttributeDBSystem, "memcached"},
+	{"ARCUS", model.AttributeDBSystem, "memcached"},
+	{"REDIS", model.AttributeDBSystem, "redis"},
+	{"IOREDIS", model.AttributeDBSystem, "redis"},
+	{"ElasticsearchBBoss", model.AttributeDBSystem, "elasticsearch"},
+	{"ELASTICSEARCH", model.AttributeDBSystem, "elasticsearch"},
+	{"THRIFT_CLIENT", model.AttributeRpcSystem, "thrift"},
+	{"DUBBO_CONSUMER", model.AttributeRpcSystem, "dubbo"},
+	{"GRPC", model.AttributeRpcSystem, "grpc"},
+	{"RABBITMQ", model.AttributeMessageSystem, "rabbitmq"},
+	{"ACTIVEMQ_CLIENT", model.AttributeMessageSystem, "activemq"},
+	{"KAFKA_CLIENT", model.AttributeMessageSystem, "kafka"},
+	{"KAFKA_STREAMS", model.AttributeMessageSystem, "kafka"},
+}
+
+// setExitSystem sets db.system, rpc.system or messaging.system of the exit span by its serviceType.
+// The commands of redis and memcached are recorded as the methods, eg. get(String key), they are set to db.operation.
+func setExitSystem(span *model.OtelSpan, serviceType string, methodName string) {
+	serviceType = strings.TrimPrefix(serviceType, "R2DBC_")
+	for _, exit := range exitSystems {
+		if !strings.HasPrefix(serviceType, exit.prefix) {
+			continue
+		}
+		span.AddAttribute(exit.attribute, exit.system)
+		if exit.system == "redis" || exit.system == "memcached" {
+			if end := strings.IndexByte(methodName, '('); end > 0 {
+				span.AddAttribute(model.AttributeDBOperation, strings.ToUpper(methodName[:end]))
+			}
+		}
+		return
+	}
+}
+
+// setRedisCommand maps the command of a redis span, eg. GET user:1. The plugins record the command as the method and
+// pinpoint-web shows the recorded arguments in the arguments column of the method row, which is empty unless the agent records them.
+func setRedisCommand(mapper *mapping.Mapper, span *model.OtelSpan, arguments string) {
+	if span.Attributes[model.AttributeDBSystem] != "redis" {
+		return
+	}
+	command := span.Attributes[model.AttributeDBOperation]
+	if command == "" {
+		return
+	}
+	if arguments != "" {
+		command += " " + arguments
+	}
+	mapper.Map(span.Attributes, annotationRedisCommand, command, span.Kind)
+}
@@ -0,0 +1,48 @@
+package pinpoint
+
+import (
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// DefaultAttributeMapping translates the titles of the annotation rows, the rules in attribute_mapping are tried before them.
+// The titles are the names of the AnnotationKeys of the agent, they differ between the plugins, eg. SQL and sql.
+var DefaultAttributeMapping = []mapping.Rule{
+	{Key: "Servlet Process", Action: mapping.ActionRename, Target: model.AttributeHTTPURL},
+	{Key: "http.url", Action: mapping.ActionRename, Target: model.AttributeHTTPURL},
+	{Key: "http.method", Action: mapping.ActionRename, Target: model.AttributeHttpMethod},
+	{Key: "http.status.code", Action: mapping.ActionRename, Target: model.AttributeHTTPStatusCode},
+
+	{Key: "SQL", Action: mapping.ActionDerive, Parser: mapping.ParserSQL, Targets: []string{model.AttributeDBOperation, model.AttributeDBSQLTable}},
+	{Key: "sql", Action: mapping.ActionDerive, Parser: mapping.ParserSQL, Targets: []string{model.AttributeDBOperation, model.AttributeDBSQLTable}},
+	{Key: "SQL", Action: mapping.ActionRename, Target: model.AttributeDBStatement},
+	{Key: "sql", Action: mapping.ActionRename, Target: model.AttributeDBStatement},
+	{Key: "SQL-BindValue", Action: mapping.ActionRename, Target: apmapi.AttributeDBStatementParameters},
+	{Key: "SQL-PARAM", Action: mapping.ActionRename, Target: apmapi.AttributeDBStatementParameters},
+	{Key: "sql.param", Action: mapping.ActionRename, Target: apmapi.AttributeDBStatementParameters},
+
+	// eg. OrderService:order2, the address is listed before the interface by the older dubbo plugins.
+	{Key: "dubbo.rpc", Action: mapping.ActionDerive, Pattern: `([^/:]+):([^/:]+)$`, Targets: []string{model.AttributeRpcService, model.AttributeRpcMethod}},
+	{Key: "thrift.url", Action: mapping.ActionDerive, Pattern: `([^/:]+):([^/:]+)$`, Targets: []string{model.AttributeRpcService, model.AttributeRpcMethod}},
+	{Key: "grpc.method", Action: mapping.ActionDerive, Pattern: `^/?(.+)/([^/]+)$`, Targets: []string{model.AttributeRpcService, model.AttributeRpcMethod}},
+
+	// The commands of the redis plugins are passed as redis.command, see setRedisCommand.
+	{Key: annotationRedisCommand, Action: mapping.ActionRename, Target: model.AttributeDBStatement},
+
+	{Key: "kafka.topic", Action: mapping.ActionRename, Target: model.AttributeMessageDestinationName},
+	{Key: "kafka.partition", Action: mapping.ActionRename, Target: "messaging.kafka.destination.partition"},
+	{Key: "kafka.offset", Action: mapping.ActionRename, Target: "messaging.kafka.message.offset"},
+	{Key: "rabbitmq.exchange", Action: mapping.ActionRename, Target: model.AttributeMessageDestinationName, KeepExisting: true},
+	{Key: "rabbitmq.routingkey", Action: mapping.ActionRename, Target: "messaging.rabbitmq.destination.routing_key"},
+	{Key: "message.queue.url", Action: mapping.ActionRename, Target: model.AttributeMessageDestinationName, KeepExisting: true},
+
+	// The other annotations, eg. the arguments of the methods, are not kept.
+	{Key: "*", Action: mapping.ActionDrop},
+}
+
+var defaultMapper = mapping.MustNew(DefaultAttributeMapping)
+
+// annotationRedisCommand is not an annotation row, the command of a redis span is built from its method row and mapped as
+// this annotation, so it can be renamed or dropped by attribute_mapping.
+const annotationRedisCommand = "redis.command"
@@ -4,79 +4,129 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
-var otSpanTagsMapping = map[string]string{
-	"Servlet Process":  model.AttributeHTTPURL,
-	"http.status.code": model.AttributeHTTPStatusCode,
-}
-
 var (
 	ErrMissRootSpan error = errors.New("miss RootSpan")
 )
 
 type PinpointResponse struct {
-	TraceId    string             `json:"transactionId"`
-	Complete   string             `json:"completeState"`
-	CallStacks [][]interface{}    `json:"callStack"`
-	Exception  *PinpointException `json:"exception"`
+	TraceId  string `json:"transactionId"`
+	Complete string `json:"completeState"`
+	// CallStackIndex maps the column names to the indexes of the callStack rows.
+	CallStackIndex map[string]int     `json:"callStackIndex"`
+	CallStacks     [][]interface{}    `json:"callStack"`
+	Exception      *PinpointException `json:"exception"`
 }
 
+// PinpointException is replied by pinpoint-web instead of the transaction when the lookup fails, eg. the trace is not found.
+// It is the stacktrace of pinpoint-web, not of the traced application.
 type PinpointException struct {
 	Stacktrace string `json:"stacktrace"`
 	Message    string `json:"message"`
 }
 
-func (resp *PinpointResponse) ConvertToServiceNodes() ([]*model.OtelServiceNode, error) {
-	traceTree := model.NewOtelTree()
+// IsComplete reports whether the spans of all agents are received, completeState is Progress or Error otherwise.
+func (resp *PinpointResponse) IsComplete() bool {
+	return resp.Complete == "Complete"
+}
+
+// ConvertToServiceNodes converts the callStack, the annotations are mapped by mapper or by DefaultAttributeMapping if mapper is nil.
+// The spans are adjusted by adjuster before the relations are built unless it is nil.
+func (resp *PinpointResponse) ConvertToServiceNodes(mapper *mapping.Mapper, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	return resp.convertToServiceNodes(mapper, adjuster, false)
+}
+
+// ConvertPartialServiceNodes converts the callStack of an incomplete trace, the rows whose parent is not received are
+// not malformed. The subtrees of the missing spans are returned after the root service with AttributeOrphanParentSpanId set.
+func (resp *PinpointResponse) ConvertPartialServiceNodes(mapper *mapping.Mapper, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	return resp.convertToServiceNodes(mapper, adjuster, true)
+}
+
+func (resp *PinpointResponse) convertToServiceNodes(mapper *mapping.Mapper, adjuster *apmapi.ClockSkewAdjuster, partial bool) ([]*model.OtelServiceNode, error) {
+	if mapper == nil {
+		mapper = defaultMapper
+	}
+	spans := make([]*model.OtelSpan, 0, len(resp.CallStacks))
 	spanMap := make(map[string]*model.OtelSpan, 0)
 	childrenSpans := make(map[string][]*model.OtelSpan, 0)
 	clientServerSpans := make(map[string]bool, 0)
 	var rootSpan *model.OtelSpan = nil
 
-	for _, callStack := range resp.CallStacks {
-		parentSpanId := callStack[7].(string)
-		if callStack[22].(bool) {
-			// HasException
-			parentSpan := spanMap[parentSpanId]
+	layout, err := newCallStackLayout(resp.CallStackIndex)
+	if err != nil {
+		return nil, err
+	}
+	for i, callStack := range resp.CallStacks {
+		row, err := parseCallStackRow(layout, i, callStack)
+		if err != nil {
+			return nil, err
+		}
+		if row.HasException {
+			parentSpan, err := getParentSpan(spanMap, i, row.ParentId)
+			if err != nil {
+				if partial {
+					continue
+				}
+				return nil, err
+			}
 			parentSpan.SetCode(model.StatusCodeError)
-			parentSpan.AddException(parentSpan.StartTime/1000, callStack[10].(string), callStack[11].(string), "")
+			message, stack := splitExceptionStack(row.Arguments)
+			parentSpan.AddException(parentSpan.StartTime/1000, row.Title, message, stack)
 			markEntrySpanError(spanMap, parentSpan)
 			continue
 		}
 
-		if !callStack[8].(bool) {
-			// Attributes
-			attributeKey := callStack[10].(string)
-			if otKey, ok := otSpanTagsMapping[attributeKey]; ok {
-				spanMap[parentSpanId].AddAttribute(otKey, callStack[11].(string))
+		if !row.IsMethod {
+			// Attributes, the parent of the annotations dropped by mapper is not checked.
+			parentSpan, exist := spanMap[row.ParentId]
+			if !exist {
+				if !partial && mapper.Keeps(row.Title, model.SpanKindUnspecified) {
+					return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] row %d refers to unknown parentId %q", i, row.ParentId)
+				}
+				continue
 			}
+			mapper.Map(parentSpan.Attributes, row.Title, row.Arguments, parentSpan.Kind)
 			continue
 		}
+		if _, exist := spanMap[row.Id]; exist {
+			return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] row %d repeats id %q", i, row.Id)
+		}
 		span := model.NewOtelSpan()
-		span.SetStartTime(uint64(callStack[1].(float64)) * 1000000)
-		span.SetDuration(uint64(callStack[2].(float64)-callStack[1].(float64)) * 1000000)
-		span.SetServiceName(callStack[4].(string))
-		span.SetName(getSpanName(callStack[17].(string), callStack[10].(string)))
-		span.SetSpanId(callStack[6].(string))
-		span.SetParentSpanId(parentSpanId)
-		if callStack[9].(bool) {
+		span.SetStartTime(uint64(row.Begin) * 1000000)
+		if row.End > row.Begin {
+			span.SetDuration(uint64(row.End-row.Begin) * 1000000)
+		}
+		span.SetServiceName(row.ApplicationName)
+		span.SetName(getSpanName(row.SimpleClassName, row.Title))
+		span.SetSpanId(row.Id)
+		span.SetParentSpanId(row.ParentId)
+		if row.HasChild {
 			// IsServer
 			span.SetKind(model.SpanKindServer)
-			url := callStack[11].(string)
+			url := row.Arguments
 			if url != "" {
-				span.AddAttribute(model.AttributeHTTPURL, callStack[11].(string))
+				span.AddAttribute(model.AttributeHTTPURL, url)
 				span.SetName(url)
 			}
 			if span.PSpanId != "" {
-				spanMap[span.PSpanId].SetKind(model.SpanKindClient)
-				clientServerSpans[span.PSpanId] = true
+				parentSpan, err := getParentSpan(spanMap, i, span.PSpanId)
+				if err == nil {
+					parentSpan.SetKind(model.SpanKindClient)
+					clientServerSpans[span.PSpanId] = true
+				} else if !partial {
+					return nil, err
+				}
 			}
 		} else {
-			span.SetKind(getSpanKind(callStack[19].(string)))
+			span.SetKind(getSpanKind(row.ApiType))
 			if span.Kind.IsExit() {
-				title := callStack[11].(string)
+				setExitSystem(span, row.ApiType, row.Title)
+				setRedisCommand(mapper, span, row.Arguments)
+				title := row.Arguments
 				if strings.Contains(title, "://") {
 					// http、dubbo、mysql
 					span.AddAttribute(model.AttributeHTTPURL, title)
@@ -84,6 +134,7 @@ func (resp *PinpointResponse) ConvertToServiceNodes() ([]*model.OtelServiceNode,
 			}
 		}
 		spanMap[span.SpanId] = span
+		spans = append(spans, span)
 
 		if span.Kind.IsEntry() && span.PSpanId == "" {
 			rootSpan = span
@@ -97,18 +148,53 @@ func (resp *PinpointResponse) ConvertToServiceNodes() ([]*model.OtelServiceNode,
 		}
 	}
 
-	if rootSpan == nil {
+	if spanId := findParentCycle(spanMap); spanId != "" {
+		return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] parentId of %q forms a cycle", spanId)
+	}
+	roots := make([]*model.OtelSpan, 0, 1)
+	if rootSpan != nil {
+		roots = append(roots, rootSpan)
+	}
+	if partial {
+		roots = append(roots, detachOrphanSpans(spans, spanMap)...)
+	}
+	if len(roots) == 0 {
 		return nil, ErrMissRootSpan
 	}
 	checkClientServerSpans(spanMap, childrenSpans, clientServerSpans)
-	checkMiddlewareSpans(childrenSpans, clientServerSpans, rootSpan)
 
-	addSpanToTree(traceTree, childrenSpans, rootSpan)
-	traceData := model.NewOTelTrace("pinpoint")
-	if err := traceTree.BuildRelation4Spans(traceData); err != nil {
-		return nil, err
+	services := make([]*model.OtelServiceNode, 0, len(roots))
+	for _, root := range roots {
+		checkMiddlewareSpans(childrenSpans, clientServerSpans, root)
+
+		traceTree := model.NewOtelTree()
+		addSpanToTree(traceTree, childrenSpans, root)
+		traceData := model.NewOTelTrace("pinpoint")
+		adjuster.Adjust(traceTree)
+		if err := traceTree.BuildRelation4Spans(traceData); err != nil {
+			return nil, err
+		}
+		services = append(services, traceData.GetServiceNodes()...)
+	}
+	return services, nil
+}
+
+// detachOrphanSpans returns the spans whose parent is not received in the order of the callStack,
+// their parentId is moved to AttributeOrphanParentSpanId so they are the roots of their subtrees.
+func detachOrphanSpans(spans []*model.OtelSpan, spanMap map[string]*model.OtelSpan) []*model.OtelSpan {
+	orphans := make([]*model.OtelSpan, 0)
+	for _, span := range spans {
+		if span.PSpanId == "" {
+			continue
+		}
+		if _, exist := spanMap[span.PSpanId]; exist {
+			continue
+		}
+		span.AddAttribute(apmapi.AttributeOrphanParentSpanId, span.PSpanId)
+		span.SetParentSpanId("")
+		orphans = append(orphans, span)
 	}
-	return traceData.GetServiceNodes(), nil
+	return orphans
 }
 
 func checkClientServerSpans(
@@ -159,14 +245,17 @@ func addSpanToTree(traceTree *model.OtelTree, childrenSpans map[string][]*model.
 }
 
 func setParentExitSpanInternal(spanMap map[string]*model.OtelSpan, parentSpanId string) {
-	parentSpan := spanMap[parentSpanId]
-	if parentSpan == nil || parentSpan.Kind.IsEntry() {
-		return
-	}
-	if parentSpan.Kind.IsExit() {
-		parentSpan.SetKind(model.SpanKindInternal)
+	// The depth is limited by the span count, parentIds of a malformed response may form a cycle.
+	for depth := 0; depth < len(spanMap); depth++ {
+		parentSpan := spanMap[parentSpanId]
+		if parentSpan == nil || parentSpan.Kind.IsEntry() {
+			return
+		}
+		if parentSpan.Kind.IsExit() {
+			parentSpan.SetKind(model.SpanKindInternal)
+		}
+		parentSpanId = parentSpan.PSpanId
 	}
-	setParentExitSpanInternal(spanMap, parentSpan.PSpanId)
 }
 
 func collectChildInfo(childrenSpans map[string][]*model.OtelSpan, parentSpan *model.OtelSpan, clientSpan *model.OtelSpan) {
@@ -186,15 +275,61 @@ func collectChildInfo(childrenSpans map[string][]*model.OtelSpan, parentSpan *mo
 	}
 }
 
+// splitExceptionStack returns the stacktrace if the agent records it after the message of an exception row,
+// eg. "closed\n\tat a.b.C.d(C.java:10)". The transactionInfo of pinpoint-web has no stack text for the exception rows,
+// they carry the class in title and the message in arguments, so the stack of the captured traces is empty.
+func splitExceptionStack(arguments string) (string, string) {
+	message, frames, found := strings.Cut(arguments, "\n")
+	if !found || !strings.HasPrefix(strings.TrimLeft(frames, " \t"), "at ") {
+		return arguments, ""
+	}
+	return message, arguments
+}
+
 func markEntrySpanError(spanMap map[string]*model.OtelSpan, parentSpan *model.OtelSpan) {
-	if parentSpan == nil {
-		return
+	for depth := 0; parentSpan != nil && depth <= len(spanMap); depth++ {
+		if parentSpan.Kind.IsEntry() {
+			parentSpan.SetCode(model.StatusCodeError)
+			return
+		}
+		parentSpan = spanMap[parentSpan.PSpanId]
 	}
-	if parentSpan.Kind.IsEntry() {
-		parentSpan.SetCode(model.StatusCodeError)
-		return
+}
+
+// findParentCycle returns a spanId on a cycle of parentIds, the collecting of children info recurses forever on a cycle.
+func findParentCycle(spanMap map[string]*model.OtelSpan) string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(spanMap))
+	for spanId := range spanMap {
+		path := make([]string, 0)
+		for current := spanId; current != ""; {
+			span, exist := spanMap[current]
+			if !exist || states[current] == visited {
+				break
+			}
+			if states[current] == visiting {
+				return current
+			}
+			states[current] = visiting
+			path = append(path, current)
+			current = span.PSpanId
+		}
+		for _, id := range path {
+			states[id] = visited
+		}
+	}
+	return ""
+}
+
+func getParentSpan(spanMap map[string]*model.OtelSpan, index int, parentSpanId string) (*model.OtelSpan, error) {
+	parentSpan, exist := spanMap[parentSpanId]
+	if !exist {
+		return nil, apmapi.NewMalformedResponseError("[x Malformed CallStack] row %d refers to unknown parentId %q", index, parentSpanId)
 	}
-	markEntrySpanError(spanMap, spanMap[parentSpan.PSpanId])
+	return parentSpan, nil
 }
 
 func getSpanName(className string, methodName string) string {
@@ -0,0 +1,160 @@
+package pinpoint
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestConvertMalformedCallStack(t *testing.T) {
+	testCases := []struct {
+		name      string
+		callStack string
+	}{
+		{"shortRow", `[["", 1, 2]]`},
+		{"wrongType", `[["", "1", 2, false, "app", 0, "1", "", true, true, "Servlet Process", "/", "", "", "", "", "", "", "", "TOMCAT", "", false, false, true]]`},
+		{"unknownParent", `[["", 1, 2, false, "app", 0, "1", "", true, true, "Servlet Process", "/", "", "", "", "", "", "", "", "TOMCAT", "", false, false, true],
+			["", 0, 0, false, null, 1, "2", "9", false, false, "http.status.code", "200", "", "", "", "", "", "", "", "", null, false, false, true]]`},
+		{"cycle", `[["", 1, 2, false, "app", 0, "1", "", true, true, "Servlet Process", "/", "", "", "", "", "", "", "", "TOMCAT", "", false, false, true],
+			["", 1, 2, false, "app", 0, "2", "3", true, false, "call", "", "", "", "", "", "", "", "", "HTTP_CLIENT_4", "", false, false, true],
+			["", 1, 2, false, "app", 0, "3", "2", true, false, "call", "", "", "", "", "", "", "", "", "HTTP_CLIENT_4", "", false, false, true],
+			["", 1, 2, false, "app", 0, "4", "2", true, true, "Servlet Process", "/", "", "", "", "", "", "", "", "TOMCAT", "", false, false, true]]`},
+	}
+	for _, testCase := range testCases {
+		resp := &PinpointResponse{}
+		if err := json.Unmarshal([]byte(`{"callStack":`+testCase.callStack+`}`), resp); err != nil {
+			t.Fatalf("[%s] %v", testCase.name, err)
+		}
+		_, err := resp.ConvertToServiceNodes(nil, nil)
+		if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeMalformedResponse {
+			t.Errorf("[Check %s] want=%s, got=%s (%v)", testCase.name, apmapi.ErrCodeMalformedResponse, got, err)
+		}
+	}
+}
+
+func TestCallStackLayout(t *testing.T) {
+	// The columns are moved and agent, which is not read, is listed first, the row is read by the names in callStackIndex.
+	callStackIndex := map[string]int{
+		"agent": 0, "id": 1, "parentId": 2, "begin": 3, "end": 4, "applicationName": 5, "isMethod": 6, "hasChild": 7,
+		"title": 8, "arguments": 9, "simpleClassName": 10, "apiType": 11, "hasException": 12,
+	}
+	layout, err := newCallStackLayout(callStackIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.version != "2.x" {
+		t.Errorf("[Check version] want=2.x, got=%s", layout.version)
+	}
+	row, err := parseCallStackRow(layout, 0, []interface{}{"app-1", "2", "1", 10.0, 20.0, "app", true, false, "call", "http://b/", "Client", "HTTP_CLIENT_4", false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &CallStackRow{Begin: 10, End: 20, ApplicationName: "app", Id: "2", ParentId: "1", IsMethod: true,
+		Title: "call", Arguments: "http://b/", SimpleClassName: "Client", ApiType: "HTTP_CLIENT_4"}
+	if !reflect.DeepEqual(expect, row) {
+		t.Errorf("[Check row] want=%+v, got=%+v", expect, row)
+	}
+
+	// agentName is added by pinpoint-web 3.x.
+	callStackIndex["agentName"] = 13
+	layout, err = newCallStackLayout(callStackIndex)
+	if err != nil || layout.version != "3.x" {
+		t.Errorf("[Check version] want=3.x, got=%+v (%v)", layout, err)
+	}
+
+	delete(callStackIndex, "hasException")
+	_, err = newCallStackLayout(callStackIndex)
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeMalformedResponse || !strings.Contains(err.Error(), `pinpoint-web 3.x misses column "hasException"`) {
+		t.Errorf("[Check missing column] want=%s with the version and the column, got=%s (%v)", apmapi.ErrCodeMalformedResponse, got, err)
+	}
+
+	layout, err = newCallStackLayout(nil)
+	if err != nil || layout.version != "2.x" || layout.minColumns != 23 {
+		t.Errorf("[Check default layout] want version=2.x, minColumns=23, got=%+v (%v)", layout, err)
+	}
+}
+
+func TestConvertAnnotations(t *testing.T) {
+	resp := &PinpointResponse{}
+	if err := json.Unmarshal([]byte(`{
+		"callStackIndex": {"id": 0, "parentId": 1, "begin": 2, "end": 3, "applicationName": 4, "isMethod": 5, "hasChild": 6,
+			"title": 7, "arguments": 8, "simpleClassName": 9, "apiType": 10, "hasException": 11},
+		"callStack": [
+			["1", "", 1, 9, "app", true, true, "Servlet Process", "/orders", "", "TOMCAT", false],
+			["2", "1", 0, 0, null, false, false, "http.method", "GET", "", "", false],
+			["3", "1", 1, 8, "app", true, false, "list()", "", "OrderController", "SPRING_BEAN", false],
+			["4", "3", 2, 3, "app", true, false, "executeQuery()", "", "PreparedStatement", "MYSQL_EXECUTE_QUERY", false],
+			["5", "4", 0, 0, null, false, false, "SQL", "select * from orders where id = ?", "", "", false],
+			["6", "4", 0, 0, null, false, false, "SQL-BindValue", "1", "", "", false],
+			["7", "3", 4, 5, "app", true, false, "get(String key)", "", "BinaryJedis", "REDIS", false],
+			["8", "3", 6, 7, "app", true, false, "send(ProducerRecord record)", "", "KafkaProducer", "KAFKA_CLIENT", false],
+			["9", "8", 0, 0, null, false, false, "kafka.topic", "orders", "", "", false],
+			["10", "3", 0, 0, null, false, false, "IllegalStateException", "closed", "", "", true]
+		]}`), resp); err != nil {
+		t.Fatal(err)
+	}
+	services, err := resp.ConvertToServiceNodes(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := services[0].EntrySpans[0].Attributes[model.AttributeHttpMethod]; got != "GET" {
+		t.Errorf("[Check http.method] want=GET, got=%s", got)
+	}
+	expects := map[string]map[string]string{
+		"4": {
+			model.AttributeDBSystem:               "mysql",
+			model.AttributeDBStatement:            "select * from orders where id = ?",
+			model.AttributeDBOperation:            "SELECT",
+			model.AttributeDBSQLTable:             "orders",
+			apmapi.AttributeDBStatementParameters: "1",
+		},
+		"7": {model.AttributeDBSystem: "redis", model.AttributeDBOperation: "GET", model.AttributeDBStatement: "GET"},
+		"8": {model.AttributeMessageSystem: "kafka", model.AttributeMessageDestinationName: "orders"},
+	}
+	if len(services[0].ExitSpans) != len(expects) {
+		t.Fatalf("[Check exit spans] want=%d, got=%d", len(expects), len(services[0].ExitSpans))
+	}
+	for _, span := range services[0].ExitSpans {
+		expect, exist := expects[span.SpanId]
+		if !exist {
+			t.Errorf("[Check exit span] unexpected span %s", span.SpanId)
+			continue
+		}
+		for key, value := range expect {
+			if got := span.Attributes[key]; got != value {
+				t.Errorf("[Check span %s %s] want=%s, got=%s", span.SpanId, key, value, got)
+			}
+		}
+	}
+
+	exception := services[0].ErrorSpans[0].Exceptions[0]
+	if exception.Type != "IllegalStateException" || exception.Message != "closed" {
+		t.Errorf("[Check exception] want=IllegalStateException: closed, got=%s: %s", exception.Type, exception.Message)
+	}
+	if exception.Stack != "" {
+		t.Errorf("[Check exception stack] want empty as the callStack has no stacktrace, got=%q", exception.Stack)
+	}
+}
+
+func TestSplitExceptionStack(t *testing.T) {
+	testCases := []struct {
+		arguments     string
+		expectMessage string
+		expectStack   string
+	}{
+		{"closed", "closed", ""},
+		{"line 1\nline 2", "line 1\nline 2", ""},
+		{"closed\n\tat a.b.C.d(C.java:10)\n", "closed", "closed\n\tat a.b.C.d(C.java:10)\n"},
+	}
+	for _, testCase := range testCases {
+		message, stack := splitExceptionStack(testCase.arguments)
+		if message != testCase.expectMessage || stack != testCase.expectStack {
+			t.Errorf("[Check %q] want=%q %q, got=%q %q", testCase.arguments, testCase.expectMessage, testCase.expectStack, message, stack)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package apmapi
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// Backend describes an APM backend, backend packages register themselves in init()
+// and are enabled by listing Name in adapter.trace_api.apm_list.
+type Backend struct {
+	// Name is the entry in apm_list and the config section under adapter.trace_api.
+	Name string
+	// ApmType is the apmType requested by APO in /trace/list.
+	ApmType string
+	// NewConfig returns a pointer to an empty config, the section adapter.trace_api.<Name> is decoded into it by mapstructure tags.
+	NewConfig func() any
+	// NewApi builds the query api from the decoded config.
+	NewApi func(conf any, timeout int64) (QueryByApmApi, error)
+	// ConvertFixture converts a raw upstream response, the data.json in testdata, to service nodes.
+	ConvertFixture func(data []byte) (traceId string, services []*model.OtelServiceNode, err error)
+}
+
+// ConfigValidator is optionally implemented by backend configs to check the required fields, prefix is the config key of the section.
+type ConfigValidator interface {
+	Validate(prefix string) error
+}
+
+var (
+	backendLock sync.RWMutex
+	backends    = make(map[string]*Backend)
+)
+
+// Register makes a backend available by name, it panics if the name is registered twice.
+func Register(backend *Backend) {
+	backendLock.Lock()
+	defer backendLock.Unlock()
+
+	if backend == nil || backend.Name == "" {
+		panic("apmapi: Register backend without name")
+	}
+	if backend.NewConfig == nil || backend.NewApi == nil {
+		panic(fmt.Sprintf("apmapi: Register backend %s without NewConfig or NewApi", backend.Name))
+	}
+	if _, exist := backends[backend.Name]; exist {
+		panic(fmt.Sprintf("apmapi: Register called twice for backend %s", backend.Name))
+	}
+	if backend.ApmType == "" {
+		backend.ApmType = backend.Name
+	}
+	backends[backend.Name] = backend
+}
+
+func GetBackend(name string) (*Backend, bool) {
+	backendLock.RLock()
+	defer backendLock.RUnlock()
+
+	backend, exist := backends[name]
+	return backend, exist
+}
+
+// ListBackends returns the registered backend names in sorted order.
+func ListBackends() []string {
+	backendLock.RLock()
+	defer backendLock.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// RemoteApi delegates the trace lookup to an out-of-process plugin over http.
+type RemoteApi struct {
+	Address string
+	Token   string
+	Timeout time.Duration
+}
+
+func NewRemoteApi(address string, token string, timeout int64) *RemoteApi {
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	return &RemoteApi{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		Timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+func (api *RemoteApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
+	return api.QueryListWithOptions(traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+}
+
+func (api *RemoteApi) QueryListWithOptions(traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, error) {
+	data, err := api.QueryRaw(traceId, startTimeMs, attributes)
+	if err != nil {
+		return nil, err
+	}
+	services, _, err := api.ConvertRaw(traceId, data, opts)
+	return services, err
+}
+
+// ConvertRaw converts a response of QueryRaw, the plugin replies 409 instead of an incomplete trace.
+func (api *RemoteApi) ConvertRaw(traceId string, data []byte, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	var response QueryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, apmapi.WrapDecodeError("remote", err)
+	}
+	if response.Version != ContractVersion {
+		return nil, false, apmapi.NewUpstreamUnavailableError(nil, "[x Version Mismatch] remote plugin replies version %q, want %q", response.Version, ContractVersion)
+	}
+	if response.GetTraceId() == "" {
+		return nil, false, apmapi.NewNotFoundError("[x Trace NotFound] Remote traceId: %s", traceId)
+	}
+	services, err := ConvertToServiceNodes(&response, opts.ClockSkew)
+	return services, true, err
+}
+
+func (api *RemoteApi) QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error) {
+	requestBody, err := json.Marshal(&QueryRequest{
+		Version:    ContractVersion,
+		TraceId:    traceId,
+		StartTime:  startTimeMs,
+		Attributes: attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.do(http.MethodPost, api.Address+QueryPath, requestBody)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("remote", err)
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp, traceId); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("remote", err)
+	}
+	return data, nil
+}
+
+func (api *RemoteApi) CheckHealth() error {
+	resp, err := api.do(http.MethodGet, api.Address+HealthPath, nil)
+	if err != nil {
+		return apmapi.WrapRequestError("remote", err)
+	}
+	defer resp.Body.Close()
+	return apmapi.CheckResponseStatus("remote", resp.StatusCode)
+}
+
+func (api *RemoteApi) do(method string, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderContractVersion, ContractVersion)
+	if len(api.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+api.Token)
+	}
+
+	client := &http.Client{
+		Timeout: api.Timeout,
+	}
+	return client.Do(req)
+}
+
+func checkResponse(resp *http.Response, traceId string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	detail := ""
+	var errResp ErrorResponse
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 4096)); err == nil && json.Unmarshal(data, &errResp) == nil && errResp.Message != "" {
+		detail = ", " + errResp.Message
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return apmapi.NewNotFoundError("[x Trace NotFound] Remote traceId: %s%s", traceId, detail)
+	case http.StatusConflict:
+		return apmapi.NewIncompleteError("[x Trace NotComplete] Remote traceId: %s%s", traceId, detail)
+	case http.StatusBadRequest:
+		return apmapi.NewBadRequestError("[x Bad Request] Remote traceId: %s%s", traceId, detail)
+	}
+	if err := apmapi.CheckResponseStatus("remote", resp.StatusCode); err != nil {
+		return fmt.Errorf("%w%s", err, detail)
+	}
+	return nil
+}
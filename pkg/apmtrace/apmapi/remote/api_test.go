@@ -0,0 +1,54 @@
+package remote_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote/remotetest"
+)
+
+const testTraceId = "5b8efff798038103d269b633813fc60c"
+
+func TestRemoteQueryList(t *testing.T) {
+	data, err := os.ReadFile("../testdata/tracelist/remote/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := remotetest.NewFakePlugin()
+	plugin.Token = "secret"
+	plugin.AddTrace(testTraceId, data)
+	plugin.AddTrace("incomplete", data)
+	plugin.MarkIncomplete("incomplete")
+	server := httptest.NewServer(plugin)
+	defer server.Close()
+
+	api := remote.NewRemoteApi(server.URL, "secret", 5)
+	if err := api.CheckHealth(); err != nil {
+		t.Errorf("[Check health] got=%v", err)
+	}
+
+	services, err := api.QueryList(testTraceId, 0, "")
+	if err != nil {
+		t.Fatalf("[Check QueryList] got=%v", err)
+	}
+	if len(services) != 1 || len(services[0].Children) != 1 {
+		t.Fatalf("[Check service tree] want 1 root with 1 child, got=%d", len(services))
+	}
+	if got := services[0].Children[0].EntrySpans[0].ServiceName; got != "order-svc" {
+		t.Errorf("[Check child service] want=order-svc, got=%s", got)
+	}
+
+	checkErrorCode(t, "notFound", api, "unknown", apmapi.ErrCodeNotFound)
+	checkErrorCode(t, "incomplete", api, "incomplete", apmapi.ErrCodeIncomplete)
+	checkErrorCode(t, "unauthorized", remote.NewRemoteApi(server.URL, "wrong", 5), testTraceId, apmapi.ErrCodeUnauthorized)
+}
+
+func checkErrorCode(t *testing.T, name string, api *remote.RemoteApi, traceId string, expect apmapi.ErrorCode) {
+	_, err := api.QueryList(traceId, 0, "")
+	if got := apmapi.GetErrorCode(err); got != expect {
+		t.Errorf("[Check %s] want=%s, got=%s (%v)", name, expect, got, err)
+	}
+}
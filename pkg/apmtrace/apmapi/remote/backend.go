@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	BackendName = "remote"
+	ApmType     = "remote"
+
+	// TransportHTTP is the only transport of the plugin contract, gRPC is out of scope.
+	TransportHTTP = "http"
+)
+
+type Config struct {
+	Address string `mapstructure:"address"`
+	Token   string `mapstructure:"token" secret:"true"`
+	// Transport to the plugin, only http is supported, empty means http.
+	Transport string `mapstructure:"transport"`
+}
+
+func (conf *Config) Validate(prefix string) error {
+	if conf.Transport != "" && conf.Transport != TransportHTTP {
+		return fmt.Errorf("%s.transport only supports %s, got %s", prefix, TransportHTTP, conf.Transport)
+	}
+	return config.ValidateURLAddress(prefix+".address", conf.Address)
+}
+
+func init() {
+	apmapi.Register(&apmapi.Backend{
+		Name:    BackendName,
+		ApmType: ApmType,
+		NewConfig: func() any {
+			return &Config{}
+		},
+		NewApi: func(conf any, timeout int64) (apmapi.QueryByApmApi, error) {
+			remoteConf := conf.(*Config)
+			if len(remoteConf.Address) == 0 {
+				return nil, errors.New("remote.address is not set")
+			}
+			return NewRemoteApi(remoteConf.Address, remoteConf.Token, timeout), nil
+		},
+		ConvertFixture: convertFixture,
+	})
+}
+
+func convertFixture(data []byte) (string, []*model.OtelServiceNode, error) {
+	response := &QueryResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return "", nil, err
+	}
+
+	services, err := ConvertToServiceNodes(response, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return response.GetTraceId(), services, nil
+}
@@ -0,0 +1,74 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+func BenchmarkConvertFixture(b *testing.B) {
+	apmtest.RunConvertBenchmarks(b, generateFanOutTrace, func(data []byte) error {
+		_, _, err := convertFixture(data)
+		return err
+	})
+}
+
+// generateFanOutTrace returns a trace of one producer publishing (spans-1)/2 messages, each consumed by one of the consumer services.
+func generateFanOutTrace(spans int) []byte {
+	const traceId = "0af7651916cd43dd8448eb211c80319c"
+	messages := (spans - 1) / 2
+	startTime := uint64(1706168391553000000)
+
+	producerSpans := make([]map[string]any, 0, 1+messages)
+	producerSpans = append(producerSpans, newBenchSpan(traceId, "r", "", "GET /orders/publish", 2, startTime, uint64(messages)*1000))
+	consumerSpans := make([][]map[string]any, apmtest.FanOutConsumerServices)
+	for i := 1; i <= messages; i++ {
+		producerId := fmt.Sprintf("p%015x", i)
+		producerSpans = append(producerSpans, newBenchSpan(traceId, producerId, "r", "orders publish", 4, startTime+uint64(i)*1000, 10000))
+		consumer := i % apmtest.FanOutConsumerServices
+		consumerSpans[consumer] = append(consumerSpans[consumer], newBenchSpan(traceId, fmt.Sprintf("c%015x", i), producerId, "orders process", 5, startTime+uint64(i)*1000+20000, 30000))
+	}
+
+	resourceSpans := []map[string]any{newBenchResourceSpan("kafka-producer", producerSpans)}
+	for i, spans := range consumerSpans {
+		resourceSpans = append(resourceSpans, newBenchResourceSpan(apmtest.FanOutConsumer(i), spans))
+	}
+	data, _ := json.Marshal(map[string]any{
+		"version":       "v1",
+		"resourceSpans": resourceSpans,
+	})
+	return data
+}
+
+func newBenchResourceSpan(serviceName string, spans []map[string]any) map[string]any {
+	return map[string]any{
+		"resource": map[string]any{
+			"attributes": []map[string]any{newBenchAttribute("service.name", serviceName)},
+		},
+		"scopeSpans": []map[string]any{{"spans": spans}},
+	}
+}
+
+func newBenchSpan(traceId string, spanId string, parentId string, name string, kind int, startTime uint64, duration uint64) map[string]any {
+	return map[string]any{
+		"traceId":           traceId,
+		"spanId":            spanId,
+		"parentSpanId":      parentId,
+		"name":              name,
+		"kind":              kind,
+		"startTimeUnixNano": fmt.Sprint(startTime),
+		"endTimeUnixNano":   fmt.Sprint(startTime + duration),
+		"attributes": []map[string]any{
+			newBenchAttribute("messaging.system", "kafka"),
+			newBenchAttribute("messaging.destination.name", "orders"),
+			{"key": "messaging.kafka.partition", "value": map[string]any{"intValue": "3"}},
+		},
+		"status": map[string]any{"code": 1},
+	}
+}
+
+func newBenchAttribute(key string, value string) map[string]any {
+	return map[string]any{"key": key, "value": map[string]any{"stringValue": value}}
+}
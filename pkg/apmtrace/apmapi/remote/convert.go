@@ -0,0 +1,229 @@
+package remote
+
+import (
+	"sort"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	attributeServiceName = "service.name"
+	eventException       = "exception"
+)
+
+func ConvertToServiceNodes(resp *QueryResponse, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	traceData := model.NewOTelTrace("remote")
+	if len(resp.ResourceSpans) == 0 {
+		return traceData.GetServiceNodes(), nil
+	}
+
+	traceTree := model.NewOtelTree()
+	for _, resourceSpan := range resp.ResourceSpans {
+		if resourceSpan == nil {
+			continue
+		}
+		serviceName := getServiceName(resourceSpan.Resource.Attributes)
+		resource := getResourceAttributes(resourceSpan.Resource.Attributes)
+		for _, scopeSpan := range resourceSpan.ScopeSpans {
+			if scopeSpan == nil {
+				continue
+			}
+			for _, span := range scopeSpan.Spans {
+				if span == nil {
+					return nil, apmapi.NewMalformedResponseError("[x Malformed Span] Remote span is null")
+				}
+				if err := traceTree.AddSpan(otlpSpanToInternal(span, serviceName, resource)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	adjuster.Adjust(traceTree)
+	if err := traceTree.BuildRelation4Spans(traceData); err != nil {
+		return nil, err
+	}
+	return traceData.GetServiceNodes(), nil
+}
+
+// GetTraceId returns the traceId of the first span.
+func (resp *QueryResponse) GetTraceId() string {
+	for _, resourceSpan := range resp.ResourceSpans {
+		if resourceSpan == nil {
+			continue
+		}
+		for _, scopeSpan := range resourceSpan.ScopeSpans {
+			if scopeSpan == nil {
+				continue
+			}
+			for _, span := range scopeSpan.Spans {
+				if span != nil {
+					return span.TraceId
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func getServiceName(attributes []*KeyValue) string {
+	for _, kv := range attributes {
+		if kv != nil && kv.Key == attributeServiceName {
+			return kv.Value.String()
+		}
+	}
+	return ""
+}
+
+// getResourceAttributes keeps apmapi.ResourceAttributes of the resource.
+func getResourceAttributes(attributes []*KeyValue) map[string]string {
+	resource := make(map[string]string)
+	for _, kv := range attributes {
+		if kv != nil && apmapi.ResourceAttributes[kv.Key] {
+			resource[kv.Key] = kv.Value.String()
+		}
+	}
+	return resource
+}
+
+func otlpSpanToInternal(span *Span, serviceName string, resource map[string]string) *model.OtelSpan {
+	dest := model.NewOtelSpan()
+	dest.SetSpanId(span.SpanId)
+	dest.SetOriginalSpanId("REMOTE", span.SpanId)
+	dest.SetParentSpanId(span.ParentSpanId)
+	dest.SetServiceName(serviceName)
+	dest.SetName(span.Name)
+	dest.SetStartTime(uint64(span.StartTimeUnixNano))
+	if span.EndTimeUnixNano > span.StartTimeUnixNano {
+		dest.SetDuration(uint64(span.EndTimeUnixNano - span.StartTimeUnixNano))
+	}
+	// OTLP enums share the values with the internal model.
+	dest.SetKind(model.OtelSpanKind(span.Kind))
+	dest.SetCode(model.OtelStatusCode(span.Status.Code))
+
+	for _, kv := range span.Attributes {
+		if kv == nil {
+			continue
+		}
+		dest.AddAttribute(kv.Key, kv.Value.String())
+	}
+	apmapi.SetResourceAttributes(dest.Attributes, resource)
+	otlpEventsToSpanEvents(span.Events, dest)
+	return dest
+}
+
+// otlpEventsToSpanEvents converts the exception events to exceptions, the other events are kept as span events.
+func otlpEventsToSpanEvents(events []*Event, dest *model.OtelSpan) {
+	spanEvents := make([]*apmapi.SpanEvent, 0)
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		attributes := make(map[string]string, len(event.Attributes))
+		for _, kv := range event.Attributes {
+			if kv == nil {
+				continue
+			}
+			attributes[kv.Key] = kv.Value.String()
+		}
+		if event.Name != eventException {
+			if len(attributes) == 0 {
+				attributes = nil
+			}
+			spanEvents = append(spanEvents, &apmapi.SpanEvent{
+				Timestamp:  uint64(event.TimeUnixNano) / 1000, // ns -> us
+				Name:       event.Name,
+				Attributes: attributes,
+			})
+			continue
+		}
+		// ns -> us
+		dest.AddException(uint64(event.TimeUnixNano)/1000,
+			attributes[model.AttributeExceptionType],
+			attributes[model.AttributeExceptionMessage],
+			attributes[model.AttributeExceptionStacktrace])
+	}
+	apmapi.SetSpanEvents(dest, spanEvents)
+}
+
+// ToResourceSpans exports the spans to OTLP JSON grouped by service, it is the reverse of ConvertToServiceNodes.
+func ToResourceSpans(traceId string, spans []*model.OtelSpan) []*ResourceSpan {
+	resourceSpans := make([]*ResourceSpan, 0)
+	serviceIndex := make(map[string]*ScopeSpan)
+	for _, span := range spans {
+		scopeSpan, exist := serviceIndex[span.ServiceName]
+		if !exist {
+			scopeSpan = &ScopeSpan{Spans: make([]*Span, 0)}
+			serviceIndex[span.ServiceName] = scopeSpan
+			resourceSpans = append(resourceSpans, &ResourceSpan{
+				Resource: Resource{
+					Attributes: []*KeyValue{newStringKeyValue(attributeServiceName, span.ServiceName)},
+				},
+				ScopeSpans: []*ScopeSpan{scopeSpan},
+			})
+		}
+		scopeSpan.Spans = append(scopeSpan.Spans, internalSpanToOtlp(traceId, span))
+	}
+	return resourceSpans
+}
+
+func internalSpanToOtlp(traceId string, span *model.OtelSpan) *Span {
+	dest := &Span{
+		TraceId:           traceId,
+		SpanId:            span.SpanId,
+		ParentSpanId:      span.PSpanId,
+		Name:              span.Name,
+		Kind:              SpanKind(span.Kind),
+		StartTimeUnixNano: Uint64(span.StartTime),
+		EndTimeUnixNano:   Uint64(span.GetEndTime()),
+		Attributes:        make([]*KeyValue, 0, len(span.Attributes)),
+		Status:            Status{Code: StatusCode(span.Code)},
+	}
+	// The span events are exported as events, the attribute is kept if it can not be decoded.
+	events, eventErr := apmapi.GetSpanEvents(span)
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		if key != apmapi.AttributeSpanEvents || eventErr != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		dest.Attributes = append(dest.Attributes, newStringKeyValue(key, span.Attributes[key]))
+	}
+	for _, event := range events {
+		eventKeys := make([]string, 0, len(event.Attributes))
+		for key := range event.Attributes {
+			eventKeys = append(eventKeys, key)
+		}
+		sort.Strings(eventKeys)
+		attributes := make([]*KeyValue, 0, len(eventKeys))
+		for _, key := range eventKeys {
+			attributes = append(attributes, newStringKeyValue(key, event.Attributes[key]))
+		}
+		dest.Events = append(dest.Events, &Event{
+			TimeUnixNano: Uint64(event.Timestamp * 1000), // us -> ns
+			Name:         event.Name,
+			Attributes:   attributes,
+		})
+	}
+	for _, exception := range span.Exceptions {
+		dest.Events = append(dest.Events, &Event{
+			TimeUnixNano: Uint64(exception.Timestamp * 1000), // us -> ns
+			Name:         eventException,
+			Attributes: []*KeyValue{
+				newStringKeyValue(model.AttributeExceptionType, exception.Type),
+				newStringKeyValue(model.AttributeExceptionMessage, exception.Message),
+				newStringKeyValue(model.AttributeExceptionStacktrace, exception.Stack),
+			},
+		})
+	}
+	return dest
+}
+
+func newStringKeyValue(key string, value string) *KeyValue {
+	return &KeyValue{
+		Key:   key,
+		Value: AnyValue{StringValue: &value},
+	}
+}
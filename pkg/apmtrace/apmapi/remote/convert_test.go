@@ -0,0 +1,53 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestOtlpEventsToSpanEvents(t *testing.T) {
+	message := "closed"
+	key := "sku:1001"
+	span := model.NewOtelSpan()
+	otlpEventsToSpanEvents([]*Event{
+		{TimeUnixNano: 1000, Name: eventException, Attributes: []*KeyValue{
+			{Key: model.AttributeExceptionType, Value: AnyValue{StringValue: &message}},
+		}},
+		{TimeUnixNano: 2000, Name: "cache.miss", Attributes: []*KeyValue{{Key: "cache.key", Value: AnyValue{StringValue: &key}}}},
+		nil,
+		{TimeUnixNano: 3000, Name: "retry"},
+	}, span)
+
+	if len(span.Exceptions) != 1 || span.Exceptions[0].Type != message {
+		t.Errorf("[Check exceptions] want=%s, got=%+v", message, span.Exceptions)
+	}
+	events, err := apmapi.GetSpanEvents(span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*apmapi.SpanEvent{
+		{Timestamp: 2, Name: "cache.miss", Attributes: map[string]string{"cache.key": key}},
+		{Timestamp: 3, Name: "retry"},
+	}
+	if !reflect.DeepEqual(expect, events) {
+		t.Errorf("[Check events] want=%+v, got=%+v", expect, events)
+	}
+
+	// The events are exported back as OTLP events instead of the attribute.
+	exported := internalSpanToOtlp("trace-1", span)
+	for _, kv := range exported.Attributes {
+		if kv.Key == apmapi.AttributeSpanEvents {
+			t.Errorf("[Check export] want the events not exported as %s", apmapi.AttributeSpanEvents)
+		}
+	}
+	names := make([]string, 0, len(exported.Events))
+	for _, event := range exported.Events {
+		names = append(names, event.Name)
+	}
+	if expectNames := []string{"cache.miss", "retry", eventException}; !reflect.DeepEqual(expectNames, names) {
+		t.Errorf("[Check export] want=%v, got=%v", expectNames, names)
+	}
+}
@@ -0,0 +1,209 @@
+package remote
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ContractVersion is the version of the plugin contract, plugins must reply with the same version.
+const ContractVersion = "v1"
+
+const (
+	// HeaderContractVersion is sent with every request to the plugin.
+	HeaderContractVersion = "X-Apo-Remote-Version"
+
+	QueryPath  = "/v1/trace"
+	HealthPath = "/v1/health"
+)
+
+// QueryRequest is posted as json to <address>/v1/trace.
+type QueryRequest struct {
+	Version    string `json:"version"`
+	TraceId    string `json:"traceId"`
+	StartTime  int64  `json:"startTime"` // ms
+	Attributes string `json:"attributes"`
+}
+
+// QueryResponse is replied by the plugin, spans are normalized to OTLP JSON (ExportTraceServiceRequest).
+// Status 404 means trace is not found, 409 means trace is not complete yet.
+type QueryResponse struct {
+	Version       string          `json:"version"`
+	ResourceSpans []*ResourceSpan `json:"resourceSpans"`
+}
+
+// ErrorResponse is optionally replied by the plugin with a non 2xx status.
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+type ResourceSpan struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []*ScopeSpan `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []*KeyValue `json:"attributes"`
+}
+
+type ScopeSpan struct {
+	Spans []*Span `json:"spans"`
+}
+
+type Span struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	ParentSpanId      string      `json:"parentSpanId"`
+	Name              string      `json:"name"`
+	Kind              SpanKind    `json:"kind"`
+	StartTimeUnixNano Uint64      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   Uint64      `json:"endTimeUnixNano"`
+	Attributes        []*KeyValue `json:"attributes"`
+	Events            []*Event    `json:"events"`
+	Status            Status      `json:"status"`
+}
+
+type Event struct {
+	TimeUnixNano Uint64      `json:"timeUnixNano"`
+	Name         string      `json:"name"`
+	Attributes   []*KeyValue `json:"attributes"`
+}
+
+type Status struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string      `json:"stringValue,omitempty"`
+	BoolValue   *bool        `json:"boolValue,omitempty"`
+	IntValue    *Uint64      `json:"intValue,omitempty"`
+	DoubleValue *float64     `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *KvlistValue `json:"kvlistValue,omitempty"`
+	BytesValue  *string      `json:"bytesValue,omitempty"`
+}
+
+type ArrayValue struct {
+	Values []*AnyValue `json:"values"`
+}
+
+type KvlistValue struct {
+	Values []*KeyValue `json:"values"`
+}
+
+// String flattens the value, arrays and kvlists are printed as json.
+func (v *AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		values := make([]string, 0, len(v.ArrayValue.Values))
+		for _, value := range v.ArrayValue.Values {
+			if value == nil {
+				continue
+			}
+			values = append(values, value.String())
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	case v.KvlistValue != nil:
+		values := make(map[string]string, len(v.KvlistValue.Values))
+		for _, kv := range v.KvlistValue.Values {
+			if kv == nil {
+				continue
+			}
+			values[kv.Key] = kv.Value.String()
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+	return ""
+}
+
+// Uint64 accepts both json string and number, OTLP JSON encodes 64 bit integers as string.
+type Uint64 uint64
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "" || str == "null" {
+		*u = 0
+		return nil
+	}
+	if value, err := strconv.ParseUint(str, 10, 64); err == nil {
+		*u = Uint64(value)
+		return nil
+	}
+	value, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return err
+	}
+	*u = Uint64(value)
+	return nil
+}
+
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatUint(uint64(u), 10) + `"`), nil
+}
+
+// SpanKind follows the OTLP enum, both the number and the name, eg. SPAN_KIND_SERVER, are accepted.
+type SpanKind int32
+
+var spanKindNames = map[string]SpanKind{
+	"SPAN_KIND_UNSPECIFIED": 0,
+	"SPAN_KIND_INTERNAL":    1,
+	"SPAN_KIND_SERVER":      2,
+	"SPAN_KIND_CLIENT":      3,
+	"SPAN_KIND_PRODUCER":    4,
+	"SPAN_KIND_CONSUMER":    5,
+}
+
+func (k *SpanKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*k = spanKindNames[name]
+		return nil
+	}
+	var value int32
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*k = SpanKind(value)
+	return nil
+}
+
+// StatusCode follows the OTLP enum, both the number and the name, eg. STATUS_CODE_ERROR, are accepted.
+type StatusCode int32
+
+var statusCodeNames = map[string]StatusCode{
+	"STATUS_CODE_UNSET": 0,
+	"STATUS_CODE_OK":    1,
+	"STATUS_CODE_ERROR": 2,
+}
+
+func (c *StatusCode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*c = statusCodeNames[name]
+		return nil
+	}
+	var value int32
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*c = StatusCode(value)
+	return nil
+}
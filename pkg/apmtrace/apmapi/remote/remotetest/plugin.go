@@ -0,0 +1,90 @@
+// Package remotetest provides a reference implementation of the remote plugin contract for tests.
+package remotetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote"
+)
+
+// FakePlugin serves traces which are stored as QueryResponse json by traceId.
+type FakePlugin struct {
+	// Token is checked against the Bearer Authorization header if not empty.
+	Token string
+
+	lock       sync.RWMutex
+	traces     map[string]json.RawMessage
+	incomplete map[string]bool
+}
+
+func NewFakePlugin() *FakePlugin {
+	return &FakePlugin{
+		traces:     make(map[string]json.RawMessage),
+		incomplete: make(map[string]bool),
+	}
+}
+
+// AddTrace stores the QueryResponse json replied for traceId.
+func (p *FakePlugin) AddTrace(traceId string, data []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.traces[traceId] = data
+}
+
+// MarkIncomplete makes the plugin reply 409 for traceId.
+func (p *FakePlugin) MarkIncomplete(traceId string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.incomplete[traceId] = true
+}
+
+func (p *FakePlugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(remote.HeaderContractVersion) != remote.ContractVersion {
+		replyError(w, http.StatusBadRequest, "unsupported contract version")
+		return
+	}
+	if p.Token != "" && r.Header.Get("Authorization") != "Bearer "+p.Token {
+		replyError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == remote.HealthPath:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && r.URL.Path == remote.QueryPath:
+		p.queryTrace(w, r)
+	default:
+		replyError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+	}
+}
+
+func (p *FakePlugin) queryTrace(w http.ResponseWriter, r *http.Request) {
+	var request remote.QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		replyError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.lock.RLock()
+	data, exist := p.traces[request.TraceId]
+	incomplete := p.incomplete[request.TraceId]
+	p.lock.RUnlock()
+	if !exist {
+		replyError(w, http.StatusNotFound, "trace is not found")
+		return
+	}
+	if incomplete {
+		replyError(w, http.StatusConflict, "trace is still flushing")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func replyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&remote.ErrorResponse{Message: message})
+}
@@ -0,0 +1,38 @@
+package apmapi
+
+// The resource attributes are copied to every span of the resource, so APO correlates the spans with the monitored hosts, pods and processes.
+const (
+	AttributeHostName          = "host.name"
+	AttributeK8sNamespaceName  = "k8s.namespace.name"
+	AttributeK8sNodeName       = "k8s.node.name"
+	AttributeK8sPodName        = "k8s.pod.name"
+	AttributeK8sPodUID         = "k8s.pod.uid"
+	AttributeContainerID       = "container.id"
+	AttributeServiceInstanceID = "service.instance.id"
+	AttributeProcessPID        = "process.pid"
+)
+
+// ResourceAttributes are kept from the backends which report every resource attribute, eg. the process tags of Jaeger,
+// the others such as process.command_line are large and not used by APO.
+var ResourceAttributes = map[string]bool{
+	AttributeHostName:          true,
+	AttributeK8sNamespaceName:  true,
+	AttributeK8sNodeName:       true,
+	AttributeK8sPodName:        true,
+	AttributeK8sPodUID:         true,
+	AttributeContainerID:       true,
+	AttributeServiceInstanceID: true,
+	AttributeProcessPID:        true,
+}
+
+// SetResourceAttributes copies the non-empty values of resource to attributes, the attributes set by the span itself are kept.
+func SetResourceAttributes(attributes map[string]string, resource map[string]string) {
+	for key, value := range resource {
+		if value == "" {
+			continue
+		}
+		if _, exist := attributes[key]; !exist {
+			attributes[key] = value
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package apmapi
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// AttributeClockSkewOffset is set to the server span shifted by the clock skew adjustment, the value is the applied offset
+// in nanoseconds like the start time of the span, eg. -1500000 if the span is moved 1.5ms earlier. Its subtree is shifted too.
+const AttributeClockSkewOffset = "apo.clock_skew.offset"
+
+// ClockSkewAdjuster shifts the subtrees of the server spans which do not fit in their client spans, the spans are
+// recorded by the wall clocks of different hosts. Like the adjuster of Jaeger, the server span is centered in the client span,
+// or aligned to its start if the server span is longer.
+type ClockSkewAdjuster struct {
+	// maxAdjustment is in nanoseconds, larger skews are left as they are. 0 does not limit the offset.
+	maxAdjustment int64
+}
+
+// NewClockSkewAdjuster returns an adjuster which applies offsets up to maxAdjustment, 0 does not limit the offset.
+func NewClockSkewAdjuster(maxAdjustment time.Duration) *ClockSkewAdjuster {
+	return &ClockSkewAdjuster{
+		maxAdjustment: maxAdjustment.Nanoseconds(),
+	}
+}
+
+// Adjust shifts the spans of tree from the root, the offset of a server span is applied to all spans under it.
+// The backends call it before BuildRelation4Spans, nothing is changed if adjuster is nil.
+func (adjuster *ClockSkewAdjuster) Adjust(tree *model.OtelTree) {
+	if adjuster == nil {
+		return
+	}
+	root := tree.GetRoot()
+	if root == nil {
+		return
+	}
+	visited := map[string]bool{root.SpanId: true}
+	adjuster.adjustChildren(tree, root, 0, visited)
+}
+
+func (adjuster *ClockSkewAdjuster) adjustChildren(tree *model.OtelTree, parent *model.OtelSpan, offset int64, visited map[string]bool) {
+	for _, childId := range tree.Children[parent.SpanId] {
+		child, exist := tree.SpanMap[childId]
+		if !exist || visited[childId] {
+			continue
+		}
+		visited[childId] = true
+
+		childOffset := offset
+		shiftSpan(child, offset)
+		if skew := adjuster.getSkew(parent, child); skew != 0 {
+			shiftSpan(child, skew)
+			child.AddAttribute(AttributeClockSkewOffset, strconv.FormatInt(skew, 10))
+			childOffset += skew
+		}
+		adjuster.adjustChildren(tree, child, childOffset, visited)
+	}
+}
+
+// getSkew returns the offset which moves the server span into its client span, 0 is returned if it fits already.
+func (adjuster *ClockSkewAdjuster) getSkew(client *model.OtelSpan, server *model.OtelSpan) int64 {
+	if client.Kind != model.SpanKindClient || server.Kind != model.SpanKindServer || client.Duration == 0 {
+		return 0
+	}
+	if server.StartTime >= client.StartTime && server.GetEndTime() <= client.GetEndTime() {
+		return 0
+	}
+	var skew int64
+	if client.Duration < server.Duration {
+		skew = int64(client.StartTime) - int64(server.StartTime)
+	} else {
+		latency := int64(client.Duration-server.Duration) / 2
+		skew = int64(client.StartTime) + latency - int64(server.StartTime)
+	}
+	if adjuster.maxAdjustment > 0 && (skew > adjuster.maxAdjustment || -skew > adjuster.maxAdjustment) {
+		return 0
+	}
+	return skew
+}
+
+// shiftSpan moves the span and the timestamps of its exceptions and events, they are in microseconds.
+func shiftSpan(span *model.OtelSpan, offset int64) {
+	if offset == 0 {
+		return
+	}
+	span.StartTime = shiftTime(span.StartTime, offset)
+	for _, exception := range span.Exceptions {
+		exception.Timestamp = shiftTime(exception.Timestamp, offset/1000)
+	}
+	if events, err := GetSpanEvents(span); err == nil && len(events) > 0 {
+		for _, event := range events {
+			event.Timestamp = shiftTime(event.Timestamp, offset/1000)
+		}
+		SetSpanEvents(span, events)
+	}
+}
+
+func shiftTime(timestamp uint64, offset int64) uint64 {
+	if offset < 0 && uint64(-offset) > timestamp {
+		return 0
+	}
+	return uint64(int64(timestamp) + offset)
+}
@@ -0,0 +1,76 @@
+package apmapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func newSkewSpan(spanId string, parentSpanId string, kind model.OtelSpanKind, startMs uint64, durationMs uint64) *model.OtelSpan {
+	span := model.NewOtelSpan()
+	span.SetSpanId(spanId)
+	span.SetParentSpanId(parentSpanId)
+	span.SetKind(kind)
+	span.SetStartTime(startMs * uint64(time.Millisecond))
+	span.SetDuration(durationMs * uint64(time.Millisecond))
+	return span
+}
+
+func newSkewTree(t *testing.T) *model.OtelTree {
+	tree := model.NewOtelTree()
+	// The clock of service b is 25ms behind, its server span starts before the client span.
+	for _, span := range []*model.OtelSpan{
+		newSkewSpan("a-server", "", model.SpanKindServer, 0, 100),
+		newSkewSpan("a-client", "a-server", model.SpanKindClient, 10, 50),
+		newSkewSpan("b-server", "a-client", model.SpanKindServer, 5, 20),
+		newSkewSpan("b-internal", "b-server", model.SpanKindInternal, 6, 10),
+		newSkewSpan("a-fit", "a-server", model.SpanKindClient, 70, 20),
+		newSkewSpan("c-server", "a-fit", model.SpanKindServer, 75, 10),
+	} {
+		if err := tree.AddSpan(span); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree.SpanMap["b-internal"].AddException(6000, "IOException", "closed", "")
+	return tree
+}
+
+func TestClockSkewAdjuster(t *testing.T) {
+	tree := newSkewTree(t)
+	NewClockSkewAdjuster(0).Adjust(tree)
+	// The server span is centered in the client span, (50 - 20) / 2 = 15ms after its start.
+	for spanId, expect := range map[string]uint64{
+		"a-server":   0,
+		"a-client":   10,
+		"b-server":   25,
+		"b-internal": 26,
+		"c-server":   75,
+	} {
+		if got := tree.SpanMap[spanId].StartTime; got != expect*uint64(time.Millisecond) {
+			t.Errorf("[Check %s startTime] want=%dms, got=%dns", spanId, expect, got)
+		}
+	}
+	if got := tree.SpanMap["b-server"].Attributes[AttributeClockSkewOffset]; got != "20000000" {
+		t.Errorf("[Check offset] want=20000000, got=%s", got)
+	}
+	if _, exist := tree.SpanMap["c-server"].Attributes[AttributeClockSkewOffset]; exist {
+		t.Errorf("[Check fitted span] want no offset")
+	}
+	if got := tree.SpanMap["b-internal"].Exceptions[0].Timestamp; got != 26000 {
+		t.Errorf("[Check exception timestamp] want=26000, got=%d", got)
+	}
+
+	tree = newSkewTree(t)
+	NewClockSkewAdjuster(10 * time.Millisecond).Adjust(tree)
+	if got := tree.SpanMap["b-server"].StartTime; got != 5*uint64(time.Millisecond) {
+		t.Errorf("[Check maxAdjustment] want unchanged, got=%dns", got)
+	}
+
+	tree = newSkewTree(t)
+	var disabled *ClockSkewAdjuster
+	disabled.Adjust(tree)
+	if got := tree.SpanMap["b-server"].StartTime; got != 5*uint64(time.Millisecond) {
+		t.Errorf("[Check disabled] want unchanged, got=%dns", got)
+	}
+}
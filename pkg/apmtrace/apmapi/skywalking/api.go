@@ -4,11 +4,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 	"unsafe"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
@@ -16,6 +19,8 @@ type SkywalkingApi struct {
 	Address string
 	Token   string
 	Timeout time.Duration
+	// Mapper maps the tags of the spans, DefaultAttributeMapping is used if it is nil.
+	Mapper *mapping.Mapper
 }
 
 func getToken(user string, password string) string {
@@ -37,30 +42,80 @@ func NewSkywalkingApi(address string, user string, passwd string, timeout int64)
 }
 
 func (sw *SkywalkingApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
-	requestBody := fmt.Sprintf(`{"query": "query queryTrace($traceId: ID!) {trace: queryTrace(traceId: $traceId) {spans{traceId segmentId spanId parentSpanId refs{traceId parentSegmentId parentSpanId type} serviceCode serviceInstanceName startTime endTime endpointName type peer component isError layer tags{key value} logs{time data {key value}}}}}","variables": {"traceId": "%s"}}`, traceId)
+	return sw.QueryListWithOptions(traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+}
+
+func (sw *SkywalkingApi) QueryListWithOptions(traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, error) {
+	data, err := sw.QueryRaw(traceId, startTimeMs, attributes)
+	if err != nil {
+		return nil, err
+	}
+	services, _, err := sw.ConvertRaw(traceId, data, opts)
+	return services, err
+}
+
+// ConvertRaw converts a response of QueryRaw with the mapper of the api, the trace is always complete.
+func (sw *SkywalkingApi) ConvertRaw(traceId string, data []byte, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	var response SkywalkingResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, apmapi.WrapDecodeError("skywalking", err)
+	}
+	if len(response.Data.Trace.Spans) == 0 {
+		return nil, false, apmapi.NewNotFoundError("[x Trace NotFound] Skywalking traceId: %s", traceId)
+	}
+
+	services, err := ConvertToServiceNodes(&response.Data.Trace, sw.Mapper, opts.ClockSkew)
+	return services, true, err
+}
+
+// traceQuery reads the spans of a trace, traceId is passed as a variable.
+const traceQuery = "query queryTrace($traceId: ID!) {trace: queryTrace(traceId: $traceId) {spans{traceId segmentId spanId parentSpanId refs{traceId parentSegmentId parentSpanId type} serviceCode serviceInstanceName startTime endTime endpointName type peer component isError layer tags{key value} logs{time data {key value}}}}}"
+
+const healthQuery = "query { __schema { queryType { name } } }"
+
+func (sw *SkywalkingApi) QueryRaw(traceId string, startTimeMs int64, attributes string) ([]byte, error) {
+	return sw.queryGraphQL(&graphQLRequest{
+		Query:     traceQuery,
+		Variables: map[string]any{"traceId": traceId},
+	})
+}
+
+func (sw *SkywalkingApi) CheckHealth() error {
+	_, err := sw.queryGraphQL(&graphQLRequest{Query: healthQuery})
+	return err
+}
+
+// queryGraphQL posts request and returns the response body, the errors replied with status 200 are returned as typed errors.
+func (sw *SkywalkingApi) queryGraphQL(request *graphQLRequest) ([]byte, error) {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, apmapi.WrapBadRequest(err)
+	}
 	headers := map[string]string{
 		"Content-Type": "application/json",
 	}
 	if len(sw.Token) > 0 {
 		headers["Authorization"] = sw.Token
 	}
-	resp, err := queryJson(sw.Address, headers, requestBody, sw.Timeout)
+	resp, err := queryJson(sw.Address, headers, string(requestBody), sw.Timeout)
 	if err != nil {
-		return nil, err
+		return nil, apmapi.WrapRequestError("skywalking", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == 401 {
-		return nil, fmt.Errorf("[x Not Authorized] Please specify username and password")
+		return nil, apmapi.NewUnauthorizedError("[x Not Authorized] Please specify username and password")
 	}
-	defer resp.Body.Close()
-	var response SkywalkingResponse
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err = apmapi.CheckResponseStatus("skywalking", resp.StatusCode); err != nil {
 		return nil, err
 	}
-	if len(response.Data.Trace.Spans) == 0 {
-		return nil, fmt.Errorf("[x Trace NotFound] Skywalking traceId: %s", traceId)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, apmapi.WrapRequestError("skywalking", err)
 	}
-
-	return ConvertToServiceNodes(&response.Data.Trace)
+	if err = checkGraphQLErrors(data); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 func queryJson(requestUrl string, headers map[string]string, body string, timeout time.Duration) (*http.Response, error) {
@@ -0,0 +1,83 @@
+package skywalking_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+)
+
+func TestSkywalkingQueryList(t *testing.T) {
+	server := apmtest.NewSkywalkingServer()
+	defer server.Close()
+
+	api := skywalking.NewSkywalkingApi(server.Address(), "", "", 1)
+	apmtest.RunQueryTests(t, server, api, time.Second, "../testdata/tracelist/skywalking")
+
+	t.Run("basicAuth", func(t *testing.T) {
+		server.SetBasicAuth("apmtest", "secret")
+		defer server.SetBasicAuth("", "")
+		authApi := skywalking.NewSkywalkingApi(server.Address(), "apmtest", "secret", 1)
+		if err := authApi.CheckHealth(); err != nil {
+			t.Errorf("[Check health with credentials] want=nil, got=%v", err)
+		}
+	})
+}
+
+func TestSkywalkingQuotedTraceId(t *testing.T) {
+	server := apmtest.NewSkywalkingServer()
+	defer server.Close()
+	data, err := os.ReadFile("../testdata/tracelist/skywalking/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The traceId is passed as a GraphQL variable, the quote must not break the request.
+	traceId := `quoted"trace\id`
+	server.AddTrace(traceId, data)
+
+	api := skywalking.NewSkywalkingApi(server.Address(), "", "", 1)
+	services, err := api.QueryList(traceId, 0, "")
+	if err != nil || len(services) == 0 {
+		t.Errorf("[Check quoted traceId] want services, got=%d (%v)", len(services), err)
+	}
+}
+
+func TestSkywalkingGraphQLErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		body   string
+		expect apmapi.ErrorCode
+	}{
+		{"unauthorized", `{"errors":[{"message":"Unauthorized: invalid token"}],"data":null}`, apmapi.ErrCodeUnauthorized},
+		{"validation", `{"errors":[{"message":"Validation error of type WrongType","extensions":{"classification":"ValidationError"}}]}`, apmapi.ErrCodeBadRequest},
+		{"timeout", `{"errors":[{"message":"Elasticsearch request timed out"}]}`, apmapi.ErrCodeTimeout},
+		{"storage", `{"errors":[{"message":"Exception while fetching data (/trace) : connection refused"}],"data":{"trace":null}}`, apmapi.ErrCodeUpstreamUnavailable},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			// OAP replies the GraphQL errors with status 200.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(testCase.body))
+			}))
+			defer server.Close()
+
+			api := skywalking.NewSkywalkingApi(strings.TrimPrefix(server.URL, "http://"), "", "", 1)
+			_, err := api.QueryList("trace-1", 0, "")
+			if got := apmapi.GetErrorCode(err); got != testCase.expect {
+				t.Errorf("[Check query] want=%s, got=%s (%v)", testCase.expect, got, err)
+			}
+			err = api.CheckHealth()
+			if got := apmapi.GetErrorCode(err); got != testCase.expect {
+				t.Errorf("[Check health] want=%s, got=%s (%v)", testCase.expect, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package skywalking
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	BackendName = "skywalking"
+	ApmType     = "skywalking"
+)
+
+type Config struct {
+	Address  string `mapstructure:"address"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password" secret:"true"`
+	// AttributeMapping is tried before DefaultAttributeMapping, eg. to map the tags of a custom plugin.
+	AttributeMapping []mapping.Rule `mapstructure:"attribute_mapping"`
+}
+
+func (conf *Config) Validate(prefix string) error {
+	return errors.Join(
+		config.ValidateHostAddress(prefix+".address", conf.Address),
+		mapping.Validate(prefix+".attribute_mapping", conf.AttributeMapping),
+	)
+}
+
+func init() {
+	apmapi.Register(&apmapi.Backend{
+		Name:    BackendName,
+		ApmType: ApmType,
+		NewConfig: func() any {
+			return &Config{}
+		},
+		NewApi: func(conf any, timeout int64) (apmapi.QueryByApmApi, error) {
+			swConf := conf.(*Config)
+			if len(swConf.Address) == 0 {
+				return nil, errors.New("skywalking.address is not set")
+			}
+			mapper, err := mapping.New(swConf.AttributeMapping, DefaultAttributeMapping)
+			if err != nil {
+				return nil, fmt.Errorf("skywalking.attribute_mapping is invalid: %w", err)
+			}
+			api := NewSkywalkingApi(swConf.Address, swConf.User, swConf.Password, timeout)
+			api.Mapper = mapper
+			return api, nil
+		},
+		ConvertFixture: convertFixture,
+	})
+}
+
+func convertFixture(data []byte) (string, []*model.OtelServiceNode, error) {
+	response := &SkywalkingResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return "", nil, err
+	}
+	if len(response.Data.Trace.Spans) == 0 {
+		return "", nil, errors.New("no skywalking span is found")
+	}
+
+	services, err := ConvertToServiceNodes(&response.Data.Trace, nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return response.Data.Trace.Spans[0].TraceId, services, nil
+}
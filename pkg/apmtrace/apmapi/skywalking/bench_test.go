@@ -0,0 +1,72 @@
+package skywalking
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+)
+
+func BenchmarkConvertFixture(b *testing.B) {
+	apmtest.RunConvertBenchmarks(b, generateFanOutTrace, func(data []byte) error {
+		_, _, err := convertFixture(data)
+		return err
+	})
+}
+
+// generateFanOutTrace returns a trace of one producer segment sending (spans-1)/2 messages, each consumed by its own segment.
+func generateFanOutTrace(spans int) []byte {
+	const traceId = "0af7651916cd43dd8448eb211c80319c.1.17061683915530001"
+	messages := (spans - 1) / 2
+	startTime := uint64(1706168391553)
+	rootSegment := "0af7651916cd43dd8448eb211c80319c.1.17061683915530000"
+	swSpans := make([]map[string]any, 0, 1+2*messages)
+	swSpans = append(swSpans, newBenchSpan(traceId, rootSegment, 0, -1, "kafka-producer", "GET:/orders/publish", "Entry", "Http", startTime, startTime+uint64(messages)))
+	for i := 1; i <= messages; i++ {
+		producer := newBenchSpan(traceId, rootSegment, i, 0, "kafka-producer", "Kafka/orders/Producer", "Exit", "MQ", startTime+uint64(i), startTime+uint64(i)+1)
+		producer["peer"] = "kafka:9092"
+		swSpans = append(swSpans, producer)
+
+		segmentId := fmt.Sprintf("%032x.2.%d", i, 17061683915530000+i)
+		consumer := newBenchSpan(traceId, segmentId, 0, -1, apmtest.FanOutConsumer(i), "Kafka/orders/Consumer/group", "Entry", "MQ", startTime+uint64(i)+2, startTime+uint64(i)+5)
+		consumer["refs"] = []map[string]any{{
+			"traceId":         traceId,
+			"parentSegmentId": rootSegment,
+			"parentSpanId":    i,
+			"type":            "CrossProcess",
+		}}
+		swSpans = append(swSpans, consumer)
+	}
+	data, _ := json.Marshal(map[string]any{
+		"data": map[string]any{
+			"trace": map[string]any{"spans": swSpans},
+		},
+	})
+	return data
+}
+
+func newBenchSpan(traceId string, segmentId string, spanId int, parentSpanId int, service string, endpoint string, spanType string, layer string, startTime uint64, endTime uint64) map[string]any {
+	return map[string]any{
+		"traceId":      traceId,
+		"segmentId":    segmentId,
+		"spanId":       spanId,
+		"parentSpanId": parentSpanId,
+		"refs":         []any{},
+		"serviceCode":  service,
+		"startTime":    startTime,
+		"endTime":      endTime,
+		"endpointName": endpoint,
+		"type":         spanType,
+		"peer":         "",
+		"component":    "kafka-producer",
+		"isError":      false,
+		"layer":        layer,
+		"tags": []map[string]string{
+			{"key": "mq.broker", "value": "kafka:9092"},
+			{"key": "mq.topic", "value": "orders"},
+			{"key": "transmission.latency", "value": "2"},
+		},
+		"logs": []any{},
+	}
+}
@@ -1,12 +1,6 @@
 package skywalking
 
-func getServer(componmentName string) string {
-	if server, ok := nameToServerMap[componmentName]; ok {
-		return server
-	}
-	return componmentName
-}
-
+// nameToServerMap translates the components and the db.type and cache.type tags to the servers.
 var nameToServerMap = map[string]string{
 	"mongodb-driver":                        "MongoDB",
 	"rocketMQ-producer":                     "RocketMQ",
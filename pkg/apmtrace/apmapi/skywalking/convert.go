@@ -4,23 +4,24 @@ import (
 	"fmt"
 	"strings"
 
-	apmclient "github.com/CloudDetail/apo-module/apm/client/v1"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 	"github.com/CloudDetail/apo-module/apm/model/v1/transform"
 )
 
-var otSpanTagsMapping = map[string]string{
-	"url":         model.AttributeURLFULL,
-	"status_code": model.AttributeHTTPStatusCode,
-}
-
 var otSpanLogsMapping = map[string]string{
 	"error.kind": model.AttributeExceptionType,
 	"message":    model.AttributeExceptionMessage,
 	"stack":      model.AttributeExceptionStacktrace,
 }
 
-func ConvertToServiceNodes(swTrace *SkywalkingTrace) ([]*model.OtelServiceNode, error) {
+// ConvertToServiceNodes converts the spans, the tags are mapped by mapper or by DefaultAttributeMapping if mapper is nil.
+// The spans are adjusted by adjuster before the relations are built unless it is nil.
+func ConvertToServiceNodes(swTrace *SkywalkingTrace, mapper *mapping.Mapper, adjuster *apmapi.ClockSkewAdjuster) ([]*model.OtelServiceNode, error) {
+	if mapper == nil {
+		mapper = defaultMapper
+	}
 	traceData := model.NewOTelTrace("skywalking")
 	if swTrace.Spans == nil && len(swTrace.Spans) == 0 {
 		return traceData.GetServiceNodes(), nil
@@ -28,20 +29,31 @@ func ConvertToServiceNodes(swTrace *SkywalkingTrace) ([]*model.OtelServiceNode,
 
 	traceTree := model.NewOtelTree()
 	for _, swSpan := range swTrace.Spans {
-		if otelSpan := swSpanToSpan(swSpan); otelSpan != nil {
+		otelSpan, err := swSpanToSpan(swSpan, mapper)
+		if err != nil {
+			return nil, err
+		}
+		if otelSpan != nil {
 			if err := traceTree.AddSpan(otelSpan); err != nil {
 				return nil, err
 			}
 		}
 	}
 
+	adjuster.Adjust(traceTree)
 	if err := traceTree.BuildRelation4Spans(traceData); err != nil {
 		return nil, err
 	}
 	return traceData.GetServiceNodes(), nil
 }
 
-func swSpanToSpan(span *SkywalkingSpan) *model.OtelSpan {
+func swSpanToSpan(span *SkywalkingSpan, mapper *mapping.Mapper) (*model.OtelSpan, error) {
+	if span == nil {
+		return nil, apmapi.NewMalformedResponseError("[x Malformed Span] Skywalking span is null")
+	}
+	if !isValidSegmentId(span.SegmentId) {
+		return nil, apmapi.NewMalformedResponseError("[x Malformed Span] Skywalking segmentId: %q", span.SegmentId)
+	}
 	dest := model.NewOtelSpan()
 	dest.SetSpanId(transform.SegmentIDToSpanID(span.SegmentId, uint32(span.SpanId)))
 	dest.SetOriginalSpanId("SKYWALKING", fmt.Sprintf("%s-%d", span.SegmentId, span.SpanId))
@@ -52,6 +64,9 @@ func swSpanToSpan(span *SkywalkingSpan) *model.OtelSpan {
 	} else if len(span.Refs) == 1 {
 		// TODO: SegmentReference references usually have only one element, but in batch consumer case, such as in MQ or async batch process, it could be multiple.
 		// We only handle one element for now.
+		if span.Refs[0] == nil || !isValidSegmentId(span.Refs[0].ParentSegmentId) {
+			return nil, apmapi.NewMalformedResponseError("[x Malformed Span] Skywalking ref of segmentId: %q", span.SegmentId)
+		}
 		dest.SetParentSpanId(transform.SegmentIDToSpanID(span.Refs[0].ParentSegmentId, uint32(span.Refs[0].ParentSpanId)))
 	}
 
@@ -67,15 +82,28 @@ func swSpanToSpan(span *SkywalkingSpan) *model.OtelSpan {
 
 	if span.SpanType == SpanType_Local {
 		if span.SpanLayer == SpanLayer_RPCFramework && span.Component == "GRPC" {
-			return nil
+			return nil, nil
 		}
 		if span.SpanLayer == SpanLayer_MQ && span.Component == "kafka-producer" {
-			return nil
+			return nil, nil
 		}
 	}
-	swKvPairsToInternalAttributes(span, dest.Attributes) // Attributes
-	swLogsToSpanEvents(span.Logs, dest)                  // Events
-	return dest
+	swKvPairsToInternalAttributes(span, dest, mapper) // Attributes
+	if _, exist := dest.Attributes[apmapi.AttributeServiceInstanceID]; !exist && span.ServiceInstanceName != "" {
+		dest.Attributes[apmapi.AttributeServiceInstanceID] = span.ServiceInstanceName
+	}
+	swLogsToSpanEvents(span.Logs, dest) // Events
+	return dest, nil
+}
+
+// isValidSegmentId checks the format parsed by transform.SegmentIDToSpanID, which panics on eg. "<32 hex>.1".
+func isValidSegmentId(segmentId string) bool {
+	if len(segmentId) == 32 {
+		return true
+	}
+	first := strings.IndexByte(segmentId, '.')
+	last := strings.LastIndexByte(segmentId, '.')
+	return first == 32 && last > first
 }
 
 func setInternalSpanStatus(span *SkywalkingSpan, dest *model.OtelSpan) {
@@ -105,13 +133,15 @@ func setInternalSpanKind(span *SkywalkingSpan, dest *model.OtelSpan) {
 	}
 }
 
+// swLogsToSpanEvents converts the logs with error.kind to exceptions, the other logs are kept as span events.
 func swLogsToSpanEvents(logs []*SkywalkingLogEntity, dest *model.OtelSpan) {
 	if len(logs) == 0 {
 		return
 	}
 
+	events := make([]*apmapi.SpanEvent, 0)
 	for _, log := range logs {
-		if len(log.Data) > 0 {
+		if log != nil && len(log.Data) > 0 {
 			attributes := make(map[string]string)
 			swKvPairsToInternalLogAttributes(log.Data, attributes)
 
@@ -121,16 +151,26 @@ func swLogsToSpanEvents(logs []*SkywalkingLogEntity, dest *model.OtelSpan) {
 				stack := attributes[model.AttributeExceptionStacktrace]
 				// ms -> us
 				dest.AddException(log.Time*1000, exceptionType, message, stack)
+				continue
+			}
+			// The fields are kept as they are, eg. message is not an exception message here.
+			fields := make(map[string]string, len(log.Data))
+			for _, pair := range log.Data {
+				if pair != nil {
+					fields[pair.Key] = pair.Value
+				}
 			}
+			events = append(events, apmapi.NewSpanEvent(log.Time*1000, fields))
 		}
 	}
+	apmapi.SetSpanEvents(dest, events)
 }
 
-func swKvPairsToInternalAttributes(span *SkywalkingSpan, dest map[string]string) {
+func swKvPairsToInternalAttributes(span *SkywalkingSpan, dest *model.OtelSpan, mapper *mapping.Mapper) {
 	if span.SpanType == SpanType_Exit {
-		dest[model.AttributeNetPeerName] = span.Peer
+		dest.Attributes[model.AttributeNetPeerName] = span.Peer
 		if span.SpanLayer == SpanLayer_RPCFramework {
-			dest[model.AttributeRpcSystem] = strings.ToLower(getServer(span.Component))
+			dest.Attributes[model.AttributeRpcSystem] = mapper.MapValue(componentKey, span.Component)
 		}
 	}
 
@@ -140,96 +180,27 @@ func swKvPairsToInternalAttributes(span *SkywalkingSpan, dest map[string]string)
 
 	if span.SpanLayer == SpanLayer_MQ {
 		if span.SpanType == SpanType_Exit || span.SpanType == SpanType_Entry {
-			dest[model.AttributeMessageSystem] = strings.ToLower(getServer(span.Component))
+			dest.Attributes[model.AttributeMessageSystem] = mapper.MapValue(componentKey, span.Component)
 		}
 	}
 
 	for _, pair := range span.Tags {
-		if setCacheAttribute(dest, pair.Key, pair.Value, span.SpanType) &&
-			setDbAttribute(dest, pair.Key, pair.Value) &&
-			setMqAttribute(dest, pair.Key, pair.Value) {
-
-			if otKey, ok := otSpanTagsMapping[pair.Key]; ok {
-				dest[otKey] = pair.Value
-			} else {
-				dest[pair.Key] = pair.Value
-			}
+		if pair == nil {
+			continue
 		}
+		mapper.Map(dest.Attributes, pair.Key, pair.Value, dest.Kind)
 	}
 }
 
-func setCacheAttribute(dest map[string]string, key string, value string, spanType SpanType) bool {
-	if !strings.HasPrefix(key, "cache.") {
-		return true
-	}
-	if spanType == SpanType_Local {
-		// EhCache...
-		dest[key] = value
-	} else if key == "cache.type" {
-		// Redis...
-		// Xmemcached -> memcached
-		dest[model.AttributeDBSystem] = strings.ToLower(getServer(value))
-	} else if key == "cache.cmd" {
-		// Redis、Memcached
-		dest[model.AttributeDBStatement] = value
-	} else if key == "cache.op" {
-		// Aerospike
-		dest[model.AttributeDBOperation] = value
-	} else {
-		dest[key] = value
-	}
-	return false
-}
-
-func setDbAttribute(dest map[string]string, key string, value string) bool {
-	if !strings.HasPrefix(key, "db.") {
-		return true
-	}
-	if key == "db.type" {
-		// Mysql...
-		dest[model.AttributeDBSystem] = strings.ToLower(getServer(value))
-	} else if key == "db.instance" {
-		dest[model.AttributeDBName] = value
-	} else if key == "db.statement" {
-		dest[model.AttributeDBStatement] = value
-		if value != "" {
-			operation, table := apmclient.SQLParseOperationAndTableNEW(value)
-			if operation != "" {
-				dest[model.AttributeDBOperation] = operation
-				dest[model.AttributeDBSQLTable] = table
-			}
-		}
-	} else {
-		dest[key] = value
-	}
-	return false
-}
-
-func setMqAttribute(dest map[string]string, key string, value string) bool {
-	if !strings.HasPrefix(key, "mq.") {
-		return true
-	}
-
-	if key == "mq.queue" {
-		dest[model.AttributeMessageDestinationName] = value
-	} else if key == "mq.topic" {
-		if _, exist := dest[model.AttributeMessageDestinationName]; !exist {
-			dest[model.AttributeMessageDestinationName] = value
-		}
-	} else if key == "mq.broker" {
-		dest[model.AttributeNetPeerName] = value
-	} else {
-		dest[key] = value
-	}
-	return false
-}
-
 func swKvPairsToInternalLogAttributes(pairs []*SkywalkingKeyValue, dest map[string]string) {
 	if len(pairs) == 0 {
 		return
 	}
 
 	for _, pair := range pairs {
+		if pair == nil {
+			continue
+		}
 		otKey, ok := otSpanLogsMapping[pair.Key]
 		if ok {
 			// Replace Tag to OTel Tag
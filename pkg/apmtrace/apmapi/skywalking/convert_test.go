@@ -0,0 +1,33 @@
+package skywalking
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestSwLogsToSpanEvents(t *testing.T) {
+	span := model.NewOtelSpan()
+	swLogsToSpanEvents([]*SkywalkingLogEntity{
+		{Time: 1, Data: []*SkywalkingKeyValue{{Key: "event", Value: "error"}, {Key: "error.kind", Value: "IOException"}, {Key: "message", Value: "closed"}}},
+		{Time: 2, Data: []*SkywalkingKeyValue{{Key: "event", Value: "order.paid"}, {Key: "message", Value: "order 1 is paid"}}},
+		{Time: 3, Data: []*SkywalkingKeyValue{{Key: "retry", Value: "2"}}},
+	}, span)
+
+	if len(span.Exceptions) != 1 || span.Exceptions[0].Type != "IOException" || span.Exceptions[0].Message != "closed" {
+		t.Errorf("[Check exceptions] want=IOException: closed, got=%+v", span.Exceptions)
+	}
+	events, err := apmapi.GetSpanEvents(span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*apmapi.SpanEvent{
+		{Timestamp: 2000, Name: "order.paid", Attributes: map[string]string{"message": "order 1 is paid"}},
+		{Timestamp: 3000, Name: "log", Attributes: map[string]string{"retry": "2"}},
+	}
+	if !reflect.DeepEqual(expect, events) {
+		t.Errorf("[Check events] want=%+v, got=%+v", expect, events)
+	}
+}
@@ -0,0 +1,33 @@
+package skywalking
+
+import (
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/mapping"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// componentKey addresses the component of the span in MapValue, eg. mysql-connector-java, it is not a tag.
+const componentKey = "sw.component"
+
+// DefaultAttributeMapping translates the tags of the SkyWalking agent plugins, the rules in attribute_mapping are tried before them.
+var DefaultAttributeMapping = []mapping.Rule{
+	// Local cache spans, eg. EhCache, keep their tags.
+	{Key: "cache.*", SpanKinds: []string{"internal"}, Action: mapping.ActionKeep},
+	// Redis... Xmemcached -> memcached
+	{Key: "cache.type", Action: mapping.ActionValueMap, Target: model.AttributeDBSystem, Values: nameToServerMap, Lowercase: true},
+	// Redis、Memcached
+	{Key: "cache.cmd", Action: mapping.ActionRename, Target: model.AttributeDBStatement},
+	// Aerospike
+	{Key: "cache.op", Action: mapping.ActionRename, Target: model.AttributeDBOperation},
+	// Mysql...
+	{Key: "db.type", Action: mapping.ActionValueMap, Target: model.AttributeDBSystem, Values: nameToServerMap, Lowercase: true},
+	{Key: "db.instance", Action: mapping.ActionRename, Target: model.AttributeDBName},
+	{Key: "db.statement", Action: mapping.ActionDerive, Parser: mapping.ParserSQL, Targets: []string{model.AttributeDBOperation, model.AttributeDBSQLTable}},
+	{Key: "mq.queue", Action: mapping.ActionRename, Target: model.AttributeMessageDestinationName},
+	{Key: "mq.topic", Action: mapping.ActionRename, Target: model.AttributeMessageDestinationName, KeepExisting: true},
+	{Key: "mq.broker", Action: mapping.ActionRename, Target: model.AttributeNetPeerName},
+	{Key: "url", Action: mapping.ActionRename, Target: model.AttributeURLFULL},
+	{Key: "status_code", Action: mapping.ActionRename, Target: model.AttributeHTTPStatusCode},
+	{Key: componentKey, Action: mapping.ActionValueMap, Values: nameToServerMap, Lowercase: true},
+}
+
+var defaultMapper = mapping.MustNew(DefaultAttributeMapping)
@@ -1,6 +1,56 @@
 package skywalking
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLError is an entry of the errors of a GraphQL response, OAP replies them with status 200,
+// eg. when the query is rejected by the authentication or the storage fails.
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// checkGraphQLErrors returns the first error of the response as a typed error, nil is returned if there is none.
+// A body which can not be decoded is left to the conversion, which reports it as a malformed response.
+func checkGraphQLErrors(data []byte) error {
+	var response struct {
+		Errors []GraphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil || len(response.Errors) == 0 {
+		return nil
+	}
+	message := response.Errors[0].Message
+	classification, _ := response.Errors[0].Extensions["classification"].(string)
+	lowerMessage := strings.ToLower(message)
+	switch {
+	case containsAny(lowerMessage, "unauthorized", "unauthenticated", "authentication", "forbidden", "permission", "access denied"):
+		return apmapi.NewUnauthorizedError("[x Not Authorized] skywalking graphql error: %s", message)
+	case containsAny(lowerMessage, "timeout", "timed out"):
+		return apmapi.NewTimeoutError(nil, "[x Upstream Timeout] skywalking graphql error: %s", message)
+	case classification == "ValidationError" || classification == "InvalidSyntax":
+		return apmapi.NewBadRequestError("[x Bad Request] skywalking graphql error: %s", message)
+	default:
+		return apmapi.NewUpstreamUnavailableError(nil, "[x Upstream Unavailable] skywalking graphql error: %s", message)
+	}
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
 
 type SkywalkingResponse struct {
 	Data SkywalkingData `json:"data"`
@@ -15,22 +65,24 @@ type SkywalkingTrace struct {
 }
 
 type SkywalkingSpan struct {
-	TraceId      string                 `json:"traceId"`
-	SegmentId    string                 `json:"segmentId"`
-	SpanId       int                    `json:"spanId"`
-	ParentSpanId int                    `json:"parentSpanId"`
-	Refs         []*SkywalkingRef       `json:"refs"`
-	ServiceCode  string                 `json:"serviceCode"`
-	StartTime    uint64                 `json:"startTime"`
-	EndTime      uint64                 `json:"endTime"`
-	EndpointName string                 `json:"endpointName"`
-	SpanType     SpanType               `json:"type"`
-	Peer         string                 `json:"peer"`
-	Component    string                 `json:"component"`
-	IsError      bool                   `json:"isError"`
-	SpanLayer    SpanLayer              `json:"layer"`
-	Tags         []*SkywalkingKeyValue  `json:"tags"`
-	Logs         []*SkywalkingLogEntity `json:"logs"`
+	TraceId      string           `json:"traceId"`
+	SegmentId    string           `json:"segmentId"`
+	SpanId       int              `json:"spanId"`
+	ParentSpanId int              `json:"parentSpanId"`
+	Refs         []*SkywalkingRef `json:"refs"`
+	ServiceCode  string           `json:"serviceCode"`
+	// ServiceInstanceName is the agent instance, eg. <uuid>@<ip> of the Java agent.
+	ServiceInstanceName string                 `json:"serviceInstanceName"`
+	StartTime           uint64                 `json:"startTime"`
+	EndTime             uint64                 `json:"endTime"`
+	EndpointName        string                 `json:"endpointName"`
+	SpanType            SpanType               `json:"type"`
+	Peer                string                 `json:"peer"`
+	Component           string                 `json:"component"`
+	IsError             bool                   `json:"isError"`
+	SpanLayer           SpanLayer              `json:"layer"`
+	Tags                []*SkywalkingKeyValue  `json:"tags"`
+	Logs                []*SkywalkingLogEntity `json:"logs"`
 }
 
 func (s *SpanType) UnmarshalJSON(data []byte) error {
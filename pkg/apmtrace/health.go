@@ -0,0 +1,125 @@
+package apmtrace
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+const (
+	HEALTH_OK   = "ok"
+	HEALTH_FAIL = "fail"
+	// HEALTH_UNKNOWN is reported for the backends which do not implement apmapi.HealthCheckApi, readiness is not assumed
+	// for a backend which can not be probed, so the report is not ready.
+	HEALTH_UNKNOWN = "unknown"
+
+	healthCacheTTL = 10 * time.Second
+)
+
+type BackendHealth struct {
+	ApmType   string `json:"apmType"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+type HealthReport struct {
+	Ready     bool             `json:"ready"`
+	CheckedAt int64            `json:"checkedAt"`
+	Backends  []*BackendHealth `json:"backends"`
+}
+
+type healthCache struct {
+	lock   sync.Mutex
+	report *HealthReport
+	expire time.Time
+	// probing is closed when the probe in flight stores its report, it is nil if no probe is running.
+	probing chan struct{}
+}
+
+// CheckHealth probes every configured backend, the report is cached for healthCacheTTL to protect upstreams from frequent probes.
+// The backends which failed to build are reported as failing. The lock only guards the cache, a slow probe does not block
+// the callers which still read a cached report. The callers arriving while a probe is in flight wait for its report
+// instead of probing the backends again.
+func (client *ApmTraceClient) CheckHealth() *HealthReport {
+	now := time.Now()
+	cache := client.health
+	cache.lock.Lock()
+	if cache.report != nil && now.Before(cache.expire) {
+		report := cache.report
+		cache.lock.Unlock()
+		return report
+	}
+	if probing := cache.probing; probing != nil {
+		cache.lock.Unlock()
+		<-probing
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		return cache.report
+	}
+	probing := make(chan struct{})
+	cache.probing = probing
+	cache.lock.Unlock()
+
+	report := probeBackends(client.apiMap, client.buildErrors)
+	report.CheckedAt = now.UnixMilli()
+	cache.lock.Lock()
+	cache.report = report
+	cache.expire = now.Add(healthCacheTTL)
+	cache.probing = nil
+	cache.lock.Unlock()
+	close(probing)
+	return report
+}
+
+func probeBackends(apiMap map[string]apmapi.QueryByApmApi, buildErrors map[string]string) *HealthReport {
+	var wg sync.WaitGroup
+	backends := make([]*BackendHealth, 0, len(apiMap)+len(buildErrors))
+	for apmType, buildError := range buildErrors {
+		backends = append(backends, &BackendHealth{
+			ApmType: apmType,
+			Status:  HEALTH_FAIL,
+			Error:   buildError,
+		})
+	}
+	for apmType, api := range apiMap {
+		backend := &BackendHealth{ApmType: apmType}
+		backends = append(backends, backend)
+
+		checker, ok := api.(apmapi.HealthCheckApi)
+		if !ok {
+			backend.Status = HEALTH_UNKNOWN
+			continue
+		}
+		wg.Add(1)
+		go func(checker apmapi.HealthCheckApi, backend *BackendHealth) {
+			defer wg.Done()
+			startTime := time.Now()
+			err := checker.CheckHealth()
+			backend.LatencyMs = time.Since(startTime).Milliseconds()
+			if err != nil {
+				backend.Status = HEALTH_FAIL
+				backend.Error = err.Error()
+			} else {
+				backend.Status = HEALTH_OK
+			}
+		}(checker, backend)
+	}
+	wg.Wait()
+
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].ApmType < backends[j].ApmType
+	})
+	report := &HealthReport{
+		Ready:    len(backends) > 0,
+		Backends: backends,
+	}
+	for _, backend := range backends {
+		if backend.Status != HEALTH_OK {
+			report.Ready = false
+		}
+	}
+	return report
+}
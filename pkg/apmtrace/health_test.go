@@ -0,0 +1,78 @@
+package apmtrace
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+// unprobedApi is a backend which does not implement apmapi.HealthCheckApi.
+type unprobedApi struct{}
+
+func (unprobedApi) QueryList(traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
+	return nil, nil
+}
+
+func TestCheckHealthConcurrent(t *testing.T) {
+	server := apmtest.NewJaegerServer()
+	defer server.Close()
+	server.SetFault(apmtest.Fault{Latency: 200 * time.Millisecond})
+	client, err := NewApmTraceClient(&config.TraceApiConfig{
+		ApmList:  []string{"jaeger"},
+		Backends: map[string]any{"jaeger": map[string]any{"address": server.Address()}},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	reports := make([]*HealthReport, 10)
+	for i := range reports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i] = client.CheckHealth()
+		}(i)
+	}
+	wg.Wait()
+	if got := server.Requests(); got != 1 {
+		t.Errorf("[Check probes] want=1, got=%d", got)
+	}
+	for i, report := range reports {
+		if report == nil || !report.Ready {
+			t.Errorf("[Check report %d] want ready, got=%+v", i, report)
+		}
+	}
+}
+
+func TestCheckHealthUnknown(t *testing.T) {
+	server := apmtest.NewJaegerServer()
+	defer server.Close()
+	client, err := NewApmTraceClient(&config.TraceApiConfig{
+		ApmList:  []string{"jaeger"},
+		Backends: map[string]any{"jaeger": map[string]any{"address": server.Address()}},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.apiMap["private"] = unprobedApi{}
+
+	report := client.CheckHealth()
+	if report.Ready {
+		t.Errorf("[Check ready] want=false with a backend which can not be probed, got=true")
+	}
+	for _, backend := range report.Backends {
+		expect := HEALTH_OK
+		if backend.ApmType != jaeger.ApmType {
+			expect = HEALTH_UNKNOWN
+		}
+		if backend.Status != expect {
+			t.Errorf("[Check %s] want=%s, got=%s", backend.ApmType, expect, backend.Status)
+		}
+	}
+}
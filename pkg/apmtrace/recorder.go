@@ -0,0 +1,101 @@
+package apmtrace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+var casePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// RecordOptions stores the raw upstream response as a regression fixture in the testdata/tracelist layout.
+type RecordOptions struct {
+	// Dir is the tracelist directory, files are written to <Dir>/<backend>/<Case>/.
+	Dir  string
+	Case string
+	// Anonymize replaces the service names and IPs besides the secrets which are always scrubbed.
+	Anonymize bool
+	// Overwrite replaces the files of an existing case, the case is refused otherwise.
+	Overwrite bool
+}
+
+// RecordTraceList queries the trace as QueryPartialTraceList and writes data.json and validate.json of the case,
+// the returned services are converted from the original response with opts, the recorded ones from the scrubbed response.
+// Nothing is recorded if the query fails, eg. a strict query of an incomplete trace.
+func (client *ApmTraceClient) RecordTraceList(apmType string, traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions, record *RecordOptions) ([]*model.OtelServiceNode, bool, error) {
+	if !casePattern.MatchString(record.Case) {
+		return nil, false, apmapi.NewBadRequestError("invalid record case: %q", record.Case)
+	}
+	api, exist := client.apiMap[apmType]
+	if !exist {
+		return nil, false, apmapi.NewBadRequestError("unknown apmType: %s", apmType)
+	}
+	backend := client.backendMap[apmType]
+	rawApi, ok := api.(apmapi.RawQueryApi)
+	convertApi, canConvert := api.(apmapi.RawConvertApi)
+	if !ok || !canConvert || backend.ConvertFixture == nil {
+		return nil, false, apmapi.NewBadRequestError("apmType %s does not support record", apmType)
+	}
+	caseDir := filepath.Join(record.Dir, backend.Name, record.Case)
+	if _, err := os.Stat(caseDir); err == nil && !record.Overwrite {
+		return nil, false, apmapi.NewBadRequestError("record case %s exists, set overwrite to replace it", caseDir)
+	}
+
+	data, err := rawApi.QueryRaw(traceId, startTimeMs, attributes)
+	if err != nil {
+		return nil, false, err
+	}
+	// The reply is converted as the query would be, eg. with attribute_mapping, the fixture with the default mapping only.
+	opts.ClockSkew = client.clockSkew
+	services, complete, err := convertApi.ConvertRaw(traceId, data, opts)
+	if err != nil {
+		return nil, false, apmapi.WrapConversionError(backend.Name, err)
+	}
+
+	scrubbed, err := ScrubRawResponse(data, record.Anonymize, collectServiceNames(services))
+	if err != nil {
+		return nil, false, apmapi.WrapConversionError(backend.Name, fmt.Errorf("scrub response: %w", err))
+	}
+	recordTraceId, recordServices, err := backend.ConvertFixture(scrubbed)
+	if err != nil {
+		return nil, false, apmapi.WrapConversionError(backend.Name, fmt.Errorf("convert scrubbed response: %w", err))
+	}
+	if err = os.MkdirAll(caseDir, 0755); err != nil {
+		return nil, false, err
+	}
+	if err = os.WriteFile(filepath.Join(caseDir, golden.DataFile), scrubbed, 0644); err != nil {
+		return nil, false, err
+	}
+	err = golden.WriteTraceCase(filepath.Join(caseDir, golden.ValidateFile), &golden.TraceCase{
+		Name:     fmt.Sprintf("%s-%s", backend.Name, record.Case),
+		TraceId:  recordTraceId,
+		Services: recordServices,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	log.Printf("[Record] apmType: %s, traceId: %s, case: %s", apmType, traceId, caseDir)
+	// The fixture keeps the plain conversion, only the reply is normalized and redacted.
+	client.processServices(backend.Name, services)
+	return services, complete, nil
+}
+
+func collectServiceNames(services []*model.OtelServiceNode) []string {
+	names := make([]string, 0)
+	for _, service := range services {
+		for _, span := range service.EntrySpans {
+			names = append(names, span.ServiceName)
+		}
+		for _, span := range service.ExitSpans {
+			names = append(names, span.ServiceName)
+		}
+		names = append(names, collectServiceNames(service.Children)...)
+	}
+	return names
+}
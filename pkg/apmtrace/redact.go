@@ -0,0 +1,364 @@
+package apmtrace
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+	"github.com/xwb1989/sqlparser"
+)
+
+// Attributes which carry the raw statement or url of a span.
+var (
+	sqlAttributes = []string{model.AttributeDBStatement, "db.query.text"}
+	urlAttributes = []string{model.AttributeHTTPURL, model.AttributeURLFULL, "http.target", "url.original"}
+	// queryAttributes carry only the query of the url, eg. url.query of semconv 1.21.
+	queryAttributes = []string{attributeURLQuery}
+	// sqlParameterAttributes are dropped as a whole when the sql is obfuscated.
+	sqlParameterAttributes = []string{apmapi.AttributeDBStatementParameters}
+)
+
+// internalAttributePrefixes mark the attributes set by the model and by the adapter, eg. apm.span.type and apo.span.events.
+// They are never dropped by the attribute filters nor rewritten by the mask rules.
+var internalAttributePrefixes = []string{"apm.", "apo."}
+
+// Redactor scrubs the converted spans before they leave the adapter, the rules are resolved per backend name.
+type Redactor struct {
+	obfuscateSQL   bool
+	stripURLQuery  bool
+	urlQueryParams map[string]bool
+
+	maskRules []*maskRule
+	filters   []*attributeFilter
+}
+
+type maskRule struct {
+	apmTypes    []string
+	attributes  []string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+type attributeFilter struct {
+	apmTypes []string
+	allow    []string
+	deny     []string
+}
+
+// NewRedactor compiles the rules of conf, nil is returned if conf is nil so the spans are returned as they are.
+func NewRedactor(conf *config.RedactionConfig) (*Redactor, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	redactor := &Redactor{
+		obfuscateSQL:   conf.ObfuscateSQL,
+		stripURLQuery:  conf.StripURLQuery,
+		urlQueryParams: make(map[string]bool, len(conf.URLQueryParams)),
+	}
+	for _, param := range conf.URLQueryParams {
+		redactor.urlQueryParams[strings.ToLower(param)] = true
+	}
+	for _, rule := range conf.MaskRules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = scrubbedValue
+		}
+		redactor.maskRules = append(redactor.maskRules, &maskRule{
+			apmTypes:    rule.ApmTypes,
+			attributes:  rule.Attributes,
+			pattern:     pattern,
+			replacement: replacement,
+		})
+	}
+	for _, filter := range conf.AttributeFilters {
+		redactor.filters = append(redactor.filters, &attributeFilter{
+			apmTypes: filter.ApmTypes,
+			allow:    filter.Allow,
+			deny:     filter.Deny,
+		})
+	}
+	return redactor, nil
+}
+
+// RedactServices rewrites the spans of the service tree in place, backendName selects the rules with apm_types.
+func (r *Redactor) RedactServices(backendName string, services []*model.OtelServiceNode) {
+	if r == nil {
+		return
+	}
+	maskRules := make([]*maskRule, 0, len(r.maskRules))
+	for _, rule := range r.maskRules {
+		if matchApmType(rule.apmTypes, backendName) {
+			maskRules = append(maskRules, rule)
+		}
+	}
+	filters := make([]*attributeFilter, 0, len(r.filters))
+	for _, filter := range r.filters {
+		if matchApmType(filter.apmTypes, backendName) {
+			filters = append(filters, filter)
+		}
+	}
+
+	forEachSpan(services, func(span *model.OtelSpan) {
+		r.redactSpan(span, maskRules, filters)
+	})
+}
+
+// forEachSpan calls handle once for every span of the service tree.
+func forEachSpan(services []*model.OtelServiceNode, handle func(span *model.OtelSpan)) {
+	// A span may be listed both as entry or exit span and as error span.
+	visited := make(map[*model.OtelSpan]bool)
+	var walk func(nodes []*model.OtelServiceNode)
+	walk = func(nodes []*model.OtelServiceNode) {
+		for _, node := range nodes {
+			for _, spans := range [][]*model.OtelSpan{node.EntrySpans, node.ExitSpans, node.ErrorSpans} {
+				for _, span := range spans {
+					if span == nil || visited[span] {
+						continue
+					}
+					visited[span] = true
+					handle(span)
+				}
+			}
+			walk(node.Children)
+		}
+	}
+	walk(services)
+}
+
+func (r *Redactor) redactSpan(span *model.OtelSpan, maskRules []*maskRule, filters []*attributeFilter) {
+	for _, filter := range filters {
+		filter.apply(span.Attributes)
+	}
+	if r.obfuscateSQL {
+		for _, key := range sqlAttributes {
+			if statement, exist := span.Attributes[key]; exist {
+				span.Attributes[key] = ObfuscateSQL(statement)
+			}
+		}
+		for _, key := range sqlParameterAttributes {
+			delete(span.Attributes, key)
+		}
+	}
+	if r.stripURLQuery {
+		r.stripSpanURLQuery(span)
+	}
+	for _, rule := range maskRules {
+		rule.apply(span)
+	}
+	redactSpanEvents(span, maskRules, filters)
+}
+
+// redactSpanEvents applies the filters and mask rules to the attributes of the events, they are encoded again afterwards.
+func redactSpanEvents(span *model.OtelSpan, maskRules []*maskRule, filters []*attributeFilter) {
+	if len(maskRules) == 0 && len(filters) == 0 {
+		return
+	}
+	events, err := apmapi.GetSpanEvents(span)
+	if err != nil || len(events) == 0 {
+		return
+	}
+	for _, event := range events {
+		for _, filter := range filters {
+			filter.apply(event.Attributes)
+		}
+		for _, rule := range maskRules {
+			rule.maskAttributes(event.Attributes)
+		}
+	}
+	apmapi.SetSpanEvents(span, events)
+}
+
+func (r *Redactor) stripSpanURLQuery(span *model.OtelSpan) {
+	for _, key := range urlAttributes {
+		rawURL, exist := span.Attributes[key]
+		if !exist {
+			continue
+		}
+		stripped := StripURLQuery(rawURL, r.urlQueryParams)
+		span.Attributes[key] = stripped
+		// The server spans of Pinpoint and SkyWalking are named by the url.
+		if span.Name == rawURL {
+			span.Name = stripped
+		}
+	}
+	for _, key := range queryAttributes {
+		query, exist := span.Attributes[key]
+		if !exist {
+			continue
+		}
+		// The query is dropped as a whole if no param is kept.
+		if filtered := filterQueryParams(query, r.urlQueryParams); filtered != "" {
+			span.Attributes[key] = filtered
+		} else {
+			delete(span.Attributes, key)
+		}
+	}
+}
+
+func (rule *maskRule) apply(span *model.OtelSpan) {
+	rule.maskAttributes(span.Attributes)
+	maskMessage := matchGlobs(rule.attributes, model.AttributeExceptionMessage)
+	maskStack := matchGlobs(rule.attributes, model.AttributeExceptionStacktrace)
+	for _, exception := range span.Exceptions {
+		if exception == nil {
+			continue
+		}
+		if maskMessage {
+			exception.Message = rule.pattern.ReplaceAllString(exception.Message, rule.replacement)
+		}
+		if maskStack {
+			exception.Stack = rule.pattern.ReplaceAllString(exception.Stack, rule.replacement)
+		}
+	}
+}
+
+func (rule *maskRule) maskAttributes(attributes map[string]string) {
+	for key, value := range attributes {
+		if !isInternalAttribute(key) && matchGlobs(rule.attributes, key) {
+			attributes[key] = rule.pattern.ReplaceAllString(value, rule.replacement)
+		}
+	}
+}
+
+func (filter *attributeFilter) apply(attributes map[string]string) {
+	for key := range attributes {
+		if isInternalAttribute(key) {
+			continue
+		}
+		if len(filter.allow) > 0 && !matchGlobs(filter.allow, key) {
+			delete(attributes, key)
+		} else if len(filter.deny) > 0 && matchGlobs(filter.deny, key) {
+			delete(attributes, key)
+		}
+	}
+}
+
+func isInternalAttribute(key string) bool {
+	for _, prefix := range internalAttributePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobs returns true if patterns is empty or name matches one of them.
+func matchGlobs(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchApmType(apmTypes []string, backendName string) bool {
+	if len(apmTypes) == 0 {
+		return true
+	}
+	for _, apmType := range apmTypes {
+		if apmType == backendName {
+			return true
+		}
+	}
+	return false
+}
+
+// StripURLQuery removes the query and fragment of rawURL, only the params are removed if params is not empty.
+func StripURLQuery(rawURL string, params map[string]bool) string {
+	base, query, hasQuery := strings.Cut(rawURL, "?")
+	if !hasQuery {
+		return rawURL
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	if len(params) == 0 {
+		return base
+	}
+
+	result := base
+	if kept := filterQueryParams(query, params); kept != "" {
+		result += "?" + kept
+	}
+	if hasFragment {
+		result += "#" + fragment
+	}
+	return result
+}
+
+// filterQueryParams removes params from query, the query is removed as a whole if params is empty.
+func filterQueryParams(query string, params map[string]bool) string {
+	if len(params) == 0 {
+		return ""
+	}
+	kept := make([]string, 0)
+	for _, pair := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !params[strings.ToLower(name)] {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// ObfuscateSQL replaces the literals of statement with ?, the rest is kept as it is written.
+// The tokenizer is the one used by apmclient to parse the operation and table, the remainder after a
+// lexical error, such as an unterminated quote, is replaced as a whole as it may contain a literal.
+func ObfuscateSQL(statement string) (result string) {
+	defer func() {
+		// The tokenizer panics on some truncated statements.
+		if recover() != nil {
+			result = "?"
+		}
+	}()
+
+	var builder strings.Builder
+	builder.Grow(len(statement))
+	tokenizer := sqlparser.NewStringTokenizer(statement)
+	written := 0
+	for {
+		tokenType, _ := tokenizer.Scan()
+		if tokenType == 0 {
+			break
+		}
+		// Position is one char past the token as the tokenizer reads ahead.
+		end := tokenizer.Position - 1
+		if end > len(statement) {
+			end = len(statement)
+		}
+		if end < written {
+			// Inconsistent position, the rest can not be split safely.
+			builder.WriteString("?")
+			return builder.String()
+		}
+		switch tokenType {
+		case sqlparser.LEX_ERROR:
+			start := written + len(statement[written:]) - len(strings.TrimLeft(statement[written:], " \t\r\n"))
+			builder.WriteString(statement[written:start])
+			builder.WriteString("?")
+			return builder.String()
+		case sqlparser.STRING, sqlparser.INTEGRAL, sqlparser.FLOAT, sqlparser.HEXNUM, sqlparser.HEX, sqlparser.BIT_LITERAL:
+			start := written + len(statement[written:end]) - len(strings.TrimLeft(statement[written:end], " \t\r\n"))
+			builder.WriteString(statement[written:start])
+			builder.WriteString("?")
+		default:
+			builder.WriteString(statement[written:end])
+		}
+		written = end
+	}
+	builder.WriteString(statement[written:])
+	return builder.String()
+}
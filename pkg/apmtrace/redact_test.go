@@ -0,0 +1,208 @@
+package apmtrace
+
+import (
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestObfuscateSQL(t *testing.T) {
+	tests := []struct {
+		statement string
+		expect    string
+	}{
+		{"select * from orders where id = 1 and email = 'a@b.com'", "select * from orders where id = ? and email = ?"},
+		{"INSERT INTO t (a, b) VALUES (-1.5, \"x\"), (0x1F, X'AB')", "INSERT INTO t (a, b) VALUES (-?, ?), (?, ?)"},
+		{"update `user` set name=? where id in (1,2) -- id 3", "update `user` set name=? where id in (?,?) -- id 3"},
+		{"select 'unterminated", "select ?"},
+		{"select * from t where name = 'it''s'", "select * from t where name = ?"},
+	}
+	for _, test := range tests {
+		if got := ObfuscateSQL(test.statement); got != test.expect {
+			t.Errorf("[Check ObfuscateSQL] statement=%s, want=%s, got=%s", test.statement, test.expect, got)
+		}
+	}
+}
+
+func TestStripURLQuery(t *testing.T) {
+	tests := []struct {
+		url    string
+		params map[string]bool
+		expect string
+	}{
+		{"http://svc/order?id=1&token=abc#top", nil, "http://svc/order"},
+		{"http://svc/order?id=1&Token=abc#top", map[string]bool{"token": true}, "http://svc/order?id=1#top"},
+		{"/order?access%5Ftoken=abc", map[string]bool{"access_token": true}, "/order"},
+		{"http://svc/order", nil, "http://svc/order"},
+	}
+	for _, test := range tests {
+		if got := StripURLQuery(test.url, test.params); got != test.expect {
+			t.Errorf("[Check StripURLQuery] url=%s, want=%s, got=%s", test.url, test.expect, got)
+		}
+	}
+}
+
+func TestRedactServices(t *testing.T) {
+	redactor, err := NewRedactor(&config.RedactionConfig{
+		ObfuscateSQL:  true,
+		StripURLQuery: true,
+		MaskRules: []config.MaskRule{
+			{Attributes: []string{"exception.message", "user.*"}, Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`},
+			{ApmTypes: []string{"jaeger"}, Pattern: `\d{16}`, Replacement: "<card>"},
+		},
+		AttributeFilters: []config.AttributeFilter{
+			{ApmTypes: []string{"pinpoint"}, Deny: []string{"http.request.header.*"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := model.NewOtelSpan()
+	entry.SetName("/order?email=a@b.com")
+	entry.SetOriginalSpanId("PINPOINT", "1")
+	entry.AddAttribute(model.AttributeHTTPURL, "/order?email=a@b.com")
+	entry.AddAttribute("http.request.header.cookie", "sid=1")
+	entry.AddAttribute("user.email", "c@d.com")
+	entry.AddAttribute("card", "4111111111111111")
+	entry.AddException(0, "NotFound", "no order of a@b.com", "at Order.get(a@b.com)")
+	exit := model.NewOtelSpan()
+	exit.AddAttribute(model.AttributeDBStatement, "select * from orders where email = 'a@b.com'")
+	exit.AddAttribute(apmapi.AttributeDBStatementParameters, "a@b.com")
+	services := []*model.OtelServiceNode{{
+		EntrySpans: []*model.OtelSpan{entry},
+		ErrorSpans: []*model.OtelSpan{entry},
+		Children: []*model.OtelServiceNode{{
+			ExitSpans: []*model.OtelSpan{exit},
+		}},
+	}}
+
+	redactor.RedactServices("pinpoint", services)
+	for key, expect := range map[string]string{
+		model.AttributeHTTPURL:       "/order",
+		"user.email":                 "***",
+		"card":                       "4111111111111111",
+		model.AttributeApmSpanType:   "PINPOINT",
+		"http.request.header.cookie": "",
+	} {
+		if got := entry.Attributes[key]; got != expect {
+			t.Errorf("[Check attribute %s] want=%q, got=%q", key, expect, got)
+		}
+	}
+	if entry.Name != "/order" {
+		t.Errorf("[Check span name] want=/order, got=%s", entry.Name)
+	}
+	if got := entry.Exceptions[0].Message; got != "no order of ***" {
+		t.Errorf("[Check exception message] got=%s", got)
+	}
+	if got := entry.Exceptions[0].Stack; got != "at Order.get(a@b.com)" {
+		t.Errorf("[Check exception stack] want unmasked as it is not listed, got=%s", got)
+	}
+	if got := exit.Attributes[model.AttributeDBStatement]; got != "select * from orders where email = ?" {
+		t.Errorf("[Check db.statement] got=%s", got)
+	}
+	if got, exist := exit.Attributes[apmapi.AttributeDBStatementParameters]; exist {
+		t.Errorf("[Check db.statement.parameters] want dropped, got=%s", got)
+	}
+
+	redactor.RedactServices("jaeger", services)
+	if got := entry.Attributes["card"]; got != "<card>" {
+		t.Errorf("[Check jaeger rule] want=<card>, got=%s", got)
+	}
+
+	var nilRedactor *Redactor
+	nilRedactor.RedactServices("jaeger", services)
+}
+
+func TestRedactSemConvQuery(t *testing.T) {
+	normalizer, err := NewSemConvNormalizer(&config.SemConvConfig{Version: "1.21"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactor, err := NewRedactor(&config.RedactionConfig{
+		StripURLQuery:  true,
+		URLQueryParams: []string{"token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := model.NewOtelSpan()
+	span.AddAttribute(model.AttributeHTTPURL, "http://shop/order?id=1&token=abc")
+	span.AddAttribute(attributeHTTPTarget, "/order?id=1&token=abc")
+	tokenOnly := model.NewOtelSpan()
+	tokenOnly.AddAttribute(attributeHTTPTarget, "/order?Token=abc")
+	services := []*model.OtelServiceNode{{EntrySpans: []*model.OtelSpan{span, tokenOnly}}}
+
+	normalizer.NormalizeServices(services)
+	redactor.RedactServices("jaeger", services)
+	for key, expect := range map[string]string{
+		model.AttributeURLFULL: "http://shop/order?id=1",
+		attributeURLPath:       "/order",
+		attributeURLQuery:      "id=1",
+	} {
+		if got := span.Attributes[key]; got != expect {
+			t.Errorf("[Check attribute %s] want=%q, got=%q", key, expect, got)
+		}
+	}
+	if got, exist := tokenOnly.Attributes[attributeURLQuery]; exist {
+		t.Errorf("[Check url.query] want dropped as no param is kept, got=%s", got)
+	}
+}
+
+func TestRedactSpanEvents(t *testing.T) {
+	redactor, err := NewRedactor(&config.RedactionConfig{
+		MaskRules: []config.MaskRule{
+			{Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`},
+			{Pattern: `\d+`, Replacement: "<n>"},
+		},
+		AttributeFilters: []config.AttributeFilter{
+			{Allow: []string{"http.*", "message"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := model.NewOtelSpan()
+	span.SetOriginalSpanId("JAEGER", "1")
+	span.AddAttribute(apmapi.AttributeOrphanParentSpanId, "12")
+	span.AddAttribute(apmapi.AttributeClockSkewOffset, "-1500000")
+	span.AddAttribute("user.email", "c@d.com")
+	apmapi.SetSpanEvents(span, []*apmapi.SpanEvent{
+		apmapi.NewSpanEvent(1707269281528153, map[string]string{"event": "mail", "message": "sent to a@b.com", "to": "a@b.com"}),
+	})
+	services := []*model.OtelServiceNode{{EntrySpans: []*model.OtelSpan{span}}}
+
+	redactor.RedactServices("jaeger", services)
+	for key, expect := range map[string]string{
+		model.AttributeApmSpanType:         "JAEGER",
+		apmapi.AttributeOrphanParentSpanId: "12",
+		apmapi.AttributeClockSkewOffset:    "-1500000",
+	} {
+		if got := span.Attributes[key]; got != expect {
+			t.Errorf("[Check internal attribute %s] want=%q, got=%q", key, expect, got)
+		}
+	}
+	if got, exist := span.Attributes["user.email"]; exist {
+		t.Errorf("[Check allow list] want user.email dropped, got=%s", got)
+	}
+	events, err := apmapi.GetSpanEvents(span)
+	if err != nil {
+		t.Fatalf("[Check events] want valid json, got err=%v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("[Check events] want=1, got=%d", len(events))
+	}
+	if events[0].Timestamp != 1707269281528153 || events[0].Name != "mail" {
+		t.Errorf("[Check event] want unchanged timestamp and name, got=%d %s", events[0].Timestamp, events[0].Name)
+	}
+	if got := events[0].Attributes["message"]; got != "sent to ***" {
+		t.Errorf("[Check event message] want=%q, got=%q", "sent to ***", got)
+	}
+	if got, exist := events[0].Attributes["to"]; exist {
+		t.Errorf("[Check event allow list] want to dropped, got=%s", got)
+	}
+}
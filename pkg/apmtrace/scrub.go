@@ -0,0 +1,131 @@
+package apmtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const scrubbedValue = "***"
+
+var (
+	// sensitiveKeyPattern matches json keys and tag keys whose values are replaced as a whole.
+	sensitiveKeyPattern = regexp.MustCompile(`(?i)(authorization|cookie|password|passwd|pwd|secret|token|api[-_.]?key|credential)`)
+	// sensitiveQueryPattern matches the secrets in urls and sql, eg. ?access_token=xxx.
+	sensitiveQueryPattern = regexp.MustCompile(`(?i)([?&;](?:[a-z_]*token|api[-_]?key|password|passwd|pwd|secret|sig|signature)=)[^&;\s"]*`)
+	// authSchemePattern matches the credentials in free text, eg. logs of the request headers.
+	authSchemePattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`)
+	ipv4Pattern       = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+)
+
+// rawScrubber rewrites every string of a raw upstream response, the json structure and numbers are kept as they are.
+type rawScrubber struct {
+	anonymize bool
+	// services is sorted by length desc, so the longer name is replaced before its prefix.
+	services    []string
+	serviceMask map[string]string
+	ipMask      map[string]string
+}
+
+// ScrubRawResponse masks the secrets of a raw upstream response, service names and IPs are replaced consistently when anonymize is set.
+func ScrubRawResponse(data []byte, anonymize bool, serviceNames []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	scrubber := &rawScrubber{
+		anonymize:   anonymize,
+		serviceMask: make(map[string]string),
+		ipMask:      make(map[string]string),
+	}
+	if anonymize {
+		scrubber.setServices(serviceNames)
+	}
+	raw = scrubber.scrub(raw)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *rawScrubber) setServices(serviceNames []string) {
+	sorted := append([]string{}, serviceNames...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if name == "" {
+			continue
+		}
+		if _, exist := s.serviceMask[name]; !exist {
+			s.serviceMask[name] = fmt.Sprintf("service-%d", len(s.serviceMask)+1)
+			s.services = append(s.services, name)
+		}
+	}
+	sort.SliceStable(s.services, func(i, j int) bool {
+		return len(s.services[i]) > len(s.services[j])
+	})
+}
+
+func (s *rawScrubber) scrub(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if _, isString := item.(string); isString && sensitiveKeyPattern.MatchString(key) {
+				v[key] = scrubbedValue
+				continue
+			}
+			v[key] = s.scrub(item)
+		}
+		// Tags are stored as {"key": "...", "value": "..."} by SkyWalking and Jaeger.
+		if key, ok := v["key"].(string); ok && sensitiveKeyPattern.MatchString(key) {
+			if _, isString := v["value"].(string); isString {
+				v["value"] = scrubbedValue
+			}
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = s.scrub(item)
+		}
+		return v
+	case string:
+		return s.scrubString(v)
+	}
+	return value
+}
+
+func (s *rawScrubber) scrubString(value string) string {
+	value = sensitiveQueryPattern.ReplaceAllString(value, "${1}"+scrubbedValue)
+	value = authSchemePattern.ReplaceAllString(value, "${1} "+scrubbedValue)
+	if !s.anonymize {
+		return value
+	}
+	value = ipv4Pattern.ReplaceAllStringFunc(value, s.maskIp)
+	for _, name := range s.services {
+		value = strings.ReplaceAll(value, name, s.serviceMask[name])
+	}
+	return value
+}
+
+func (s *rawScrubber) maskIp(ip string) string {
+	if ip == "127.0.0.1" || ip == "0.0.0.0" {
+		return ip
+	}
+	if masked, exist := s.ipMask[ip]; exist {
+		return masked
+	}
+	index := len(s.ipMask) + 1
+	masked := fmt.Sprintf("10.0.%d.%d", index/250, index%250+1)
+	s.ipMask[ip] = masked
+	return masked
+}
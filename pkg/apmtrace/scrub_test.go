@@ -0,0 +1,40 @@
+package apmtrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScrubRawResponse(t *testing.T) {
+	raw := `{"data":{"trace":{"spans":[{"serviceCode":"order-svc","peer":"10.1.2.3:8080","startTime":1730811346637,
+		"tags":[{"key":"http.url","value":"http://10.1.2.3/order?id=1&access_token=abc"},{"key":"db.password","value":"p@ss"},
+		{"key":"http.header","value":"Authorization: Bearer eyJhbGciOi"}],"password":"root"}]}}}`
+
+	scrubbed, err := ScrubRawResponse([]byte(raw), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(scrubbed)
+	for _, secret := range []string{"abc", "p@ss", "eyJhbGciOi", `"root"`} {
+		if strings.Contains(got, secret) {
+			t.Errorf("[Check scrub] secret %s is not scrubbed: %s", secret, got)
+		}
+	}
+	for _, expect := range []string{"order-svc", "10.1.2.3", "1730811346637", "Bearer ***", "access_token=***"} {
+		if !strings.Contains(got, expect) {
+			t.Errorf("[Check scrub] want=%s, got=%s", expect, got)
+		}
+	}
+
+	anonymized, err := ScrubRawResponse([]byte(raw), true, []string{"order-svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = string(anonymized)
+	if strings.Contains(got, "order-svc") || strings.Contains(got, "10.1.2.3") {
+		t.Errorf("[Check anonymize] got=%s", got)
+	}
+	if strings.Count(got, "10.0.0.2") != 2 {
+		t.Errorf("[Check anonymize] want the same ip masked consistently, got=%s", got)
+	}
+}
@@ -0,0 +1,142 @@
+package apmtrace
+
+import (
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const (
+	attributeURLPath    = "url.path"
+	attributeURLQuery   = "url.query"
+	attributeHTTPTarget = "http.target"
+)
+
+// semconvRename is an attribute renamed by the semantic conventions, new is emitted since 1.<since>.
+type semconvRename struct {
+	old   string
+	new   string
+	since int
+	// kinds limits the rename to the entry or exit spans, eg. net.peer.name is the server only on the client side.
+	kinds func(kind model.OtelSpanKind) bool
+}
+
+var semconvRenames = []semconvRename{
+	{old: model.AttributeMessageDestination, new: model.AttributeMessageDestinationName, since: 17},
+	// HTTP and networking, https://opentelemetry.io/docs/specs/semconv/non-normative/http-migration/
+	{old: model.AttributeHTTPURL, new: model.AttributeURLFULL, since: 21},
+	{old: model.AttributeHttpMethod, new: model.AttributeHttpRequestMethod, since: 21},
+	{old: model.AttributeHTTPStatusCode, new: "http.response.status_code", since: 21},
+	{old: "http.scheme", new: "url.scheme", since: 21},
+	{old: "http.user_agent", new: "user_agent.original", since: 21},
+	{old: "http.client_ip", new: "client.address", since: 21},
+	{old: "http.request_content_length", new: "http.request.body.size", since: 21},
+	{old: "http.response_content_length", new: "http.response.body.size", since: 21},
+	{old: model.AttributeNetPeerName, new: model.AttributeServerAddress, since: 21, kinds: model.OtelSpanKind.IsExit},
+	{old: model.AttributeNetPeerPort, new: model.AttributeServerPort, since: 21, kinds: model.OtelSpanKind.IsExit},
+	{old: "net.host.name", new: model.AttributeServerAddress, since: 21, kinds: model.OtelSpanKind.IsEntry},
+	{old: "net.host.port", new: model.AttributeServerPort, since: 21, kinds: model.OtelSpanKind.IsEntry},
+	{old: model.AttributeNetSockPeerAddr, new: model.AttributeNetworkPeerAddress, since: 21},
+	{old: model.AttributeNetSockPeerPort, new: model.AttributeNetworkPeerPort, since: 21},
+	// Database
+	{old: model.AttributeDBStatement, new: "db.query.text", since: 26},
+	{old: model.AttributeDBOperation, new: "db.operation.name", since: 26},
+	{old: model.AttributeDBName, new: "db.namespace", since: 26},
+	{old: model.AttributeDBSQLTable, new: "db.collection.name", since: 26},
+}
+
+// SemConvNormalizer rewrites the attributes converted from every backend to the keys of one semantic conventions version.
+type SemConvNormalizer struct {
+	minor    int
+	dualEmit bool
+}
+
+// NewSemConvNormalizer returns nil if conf is nil, so the attributes are returned as the backends convert them.
+func NewSemConvNormalizer(conf *config.SemConvConfig) (*SemConvNormalizer, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	_, minor, err := conf.ParseVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &SemConvNormalizer{
+		minor:    minor,
+		dualEmit: conf.DualEmit,
+	}, nil
+}
+
+// NormalizeServices rewrites the attributes of the spans in place.
+func (n *SemConvNormalizer) NormalizeServices(services []*model.OtelServiceNode) {
+	if n == nil {
+		return
+	}
+	forEachSpan(services, func(span *model.OtelSpan) {
+		n.normalizeAttributes(span.Attributes, span.Kind)
+	})
+}
+
+func (n *SemConvNormalizer) normalizeAttributes(attributes map[string]string, kind model.OtelSpanKind) {
+	for _, rename := range semconvRenames {
+		if rename.kinds != nil && !rename.kinds(kind) {
+			continue
+		}
+		// The new key wins if the span carries both.
+		value, exist := attributes[rename.new]
+		if !exist {
+			value, exist = attributes[rename.old]
+		}
+		if exist {
+			n.emit(attributes, rename.old, rename.new, value, n.minor >= rename.since)
+		}
+	}
+	n.normalizeHTTPTarget(attributes)
+}
+
+func (n *SemConvNormalizer) emit(attributes map[string]string, oldKey string, newKey string, value string, useNew bool) {
+	if useNew || n.dualEmit {
+		attributes[newKey] = value
+	} else {
+		delete(attributes, newKey)
+	}
+	if !useNew || n.dualEmit {
+		attributes[oldKey] = value
+	} else {
+		delete(attributes, oldKey)
+	}
+}
+
+// normalizeHTTPTarget splits http.target into url.path and url.query since 1.21, or joins them before.
+func (n *SemConvNormalizer) normalizeHTTPTarget(attributes map[string]string) {
+	target, hasTarget := attributes[attributeHTTPTarget]
+	path, hasPath := attributes[attributeURLPath]
+	query, hasQuery := attributes[attributeURLQuery]
+	if !hasTarget && !hasPath {
+		return
+	}
+	if hasPath {
+		target = path
+		if hasQuery && query != "" {
+			target += "?" + query
+		}
+	} else {
+		path, query, hasQuery = strings.Cut(target, "?")
+	}
+
+	useNew := n.minor >= 21
+	if useNew || n.dualEmit {
+		attributes[attributeURLPath] = path
+		if hasQuery {
+			attributes[attributeURLQuery] = query
+		}
+	} else {
+		delete(attributes, attributeURLPath)
+		delete(attributes, attributeURLQuery)
+	}
+	if !useNew || n.dualEmit {
+		attributes[attributeHTTPTarget] = target
+	} else {
+		delete(attributes, attributeHTTPTarget)
+	}
+}
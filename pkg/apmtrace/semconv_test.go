@@ -0,0 +1,74 @@
+package apmtrace
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+func TestNormalizeServices(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       *config.SemConvConfig
+		kind       model.OtelSpanKind
+		attributes map[string]string
+		expect     map[string]string
+	}{
+		{
+			name: "newHTTPClient",
+			conf: &config.SemConvConfig{Version: "1.26.0"},
+			kind: model.SpanKindClient,
+			attributes: map[string]string{
+				"http.url": "http://svc/orders?id=1", "http.method": "GET", "http.status_code": "200",
+				"net.peer.name": "svc", "db.statement": "select 1",
+			},
+			expect: map[string]string{
+				"url.full": "http://svc/orders?id=1", "http.request.method": "GET", "http.response.status_code": "200",
+				"server.address": "svc", "db.query.text": "select 1",
+			},
+		},
+		{
+			name:       "newHTTPServer",
+			conf:       &config.SemConvConfig{Version: "1.21"},
+			kind:       model.SpanKindServer,
+			attributes: map[string]string{"http.target": "/orders?id=1", "net.peer.name": "10.0.0.1", "db.statement": "select 1"},
+			expect:     map[string]string{"url.path": "/orders", "url.query": "id=1", "net.peer.name": "10.0.0.1", "db.statement": "select 1"},
+		},
+		{
+			name:       "old",
+			conf:       &config.SemConvConfig{Version: "1.20.0"},
+			kind:       model.SpanKindServer,
+			attributes: map[string]string{"url.full": "http://svc/orders", "url.path": "/orders", "url.query": "id=1", "http.status_code": "500"},
+			expect:     map[string]string{"http.url": "http://svc/orders", "http.target": "/orders?id=1", "http.status_code": "500"},
+		},
+		{
+			name:       "dualEmit",
+			conf:       &config.SemConvConfig{Version: "1.26.0", DualEmit: true},
+			kind:       model.SpanKindClient,
+			attributes: map[string]string{"http.url": "http://old", "url.full": "http://new", "messaging.destination": "orders"},
+			expect: map[string]string{
+				"http.url": "http://new", "url.full": "http://new",
+				"messaging.destination": "orders", "messaging.destination.name": "orders",
+			},
+		},
+	}
+	for _, test := range tests {
+		normalizer, err := NewSemConvNormalizer(test.conf)
+		if err != nil {
+			t.Fatalf("[%s] %v", test.name, err)
+		}
+		span := model.NewOtelSpan()
+		span.SetKind(test.kind)
+		span.Attributes = test.attributes
+		normalizer.NormalizeServices([]*model.OtelServiceNode{{EntrySpans: []*model.OtelSpan{span}}})
+		if !reflect.DeepEqual(test.expect, span.Attributes) {
+			t.Errorf("[Check %s] want=%v, got=%v", test.name, test.expect, span.Attributes)
+		}
+	}
+
+	if _, err := NewSemConvNormalizer(&config.SemConvConfig{Version: "2.0"}); err == nil {
+		t.Error("[Check version 2.0] want error, got nil")
+	}
+}
@@ -4,23 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
-	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/elastic"
-	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
-	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/pinpoint"
-	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
 	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
 	"github.com/CloudDetail/apo-module/apm/model/v1"
 )
 
 const (
-	APMTYPE_SW         = "skywalking"
-	OTEL_EXPORT_JAEGER = "jaeger"
-	APMTYPE_OTEL       = "otel"
-	APMTYPE_ELASTIC    = "elastic"
-	APMTYPE_PINPOINT   = "pinpoint"
-
 	INVALID_API = "[x Build %s] %s is not set"
 	VALID_API   = "[Build TraceApi] %s"
 )
@@ -30,102 +21,117 @@ var (
 )
 
 type ApmTraceClient struct {
-	apiMap map[string]apmapi.QueryByApmApi
+	apiMap     map[string]apmapi.QueryByApmApi
+	backendMap map[string]*apmapi.Backend
+	normalizer *SemConvNormalizer
+	redactor   *Redactor
+	// clockSkew adjusts the spans converted by the queries, it is not applied to the recorded fixtures.
+	clockSkew *apmapi.ClockSkewAdjuster
+	// buildErrors keeps why the backends in apm_list are not built by apmType, they are reported as failing by CheckHealth.
+	buildErrors map[string]string
+	health      *healthCache
 }
 
+// NewApmTraceClient builds the api of every backend in apm_list through the apmapi registry.
 func NewApmTraceClient(conf *config.TraceApiConfig, timeout int64) (*ApmTraceClient, error) {
 	apiMap := make(map[string]apmapi.QueryByApmApi, 0)
+	backendMap := make(map[string]*apmapi.Backend, 0)
+	buildErrors := make(map[string]string, 0)
 	for _, apmType := range conf.ApmList {
-		switch apmType {
-		case APMTYPE_SW:
-			buildSkywalkingApi(conf.Skywalking, apiMap, timeout)
-		case OTEL_EXPORT_JAEGER:
-			buildJaegerApi(conf.Jaeger, apiMap, timeout)
-		case APMTYPE_ELASTIC:
-			buildEsapmApi(conf.Elastic, apiMap, timeout)
-		case APMTYPE_PINPOINT:
-			buildPinpointApi(conf.Pinpoint, apiMap, timeout)
-		default:
+		backend, exist := apmapi.GetBackend(apmType)
+		if !exist {
 			log.Printf("Unknonw apmType: %s", apmType)
+			buildErrors[apmType] = fmt.Sprintf("unknown apmType %s", apmType)
+			continue
+		}
+		backendCfg, err := conf.DecodeBackend(apmType)
+		if err != nil {
+			log.Printf("[x Build %s] %v", apmType, err)
+			buildErrors[backend.ApmType] = err.Error()
+			continue
+		}
+		if backendCfg == nil {
+			log.Printf(INVALID_API, apmType, apmType)
+			buildErrors[backend.ApmType] = fmt.Sprintf("%s is not set", apmType)
+			continue
 		}
+		api, err := backend.NewApi(backendCfg, timeout)
+		if err != nil {
+			log.Printf("[x Build %s] %v", apmType, err)
+			buildErrors[backend.ApmType] = err.Error()
+			continue
+		}
+		log.Printf(VALID_API, apmType)
+		apiMap[backend.ApmType] = api
+		backendMap[backend.ApmType] = backend
 	}
 
 	if len(apiMap) == 0 {
 		return nil, ErrNoAvaiableApmType
 	}
+	normalizer, err := NewSemConvNormalizer(conf.SemConv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid adapter.trace_api.semconv: %w", err)
+	}
+	redactor, err := NewRedactor(conf.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("invalid adapter.trace_api.redaction: %w", err)
+	}
 
 	return &ApmTraceClient{
-		apiMap: apiMap,
+		apiMap:      apiMap,
+		backendMap:  backendMap,
+		normalizer:  normalizer,
+		redactor:    redactor,
+		clockSkew:   newClockSkewAdjuster(conf.ClockSkew),
+		buildErrors: buildErrors,
+		health:      &healthCache{},
 	}, nil
 }
 
-func buildSkywalkingApi(conf *config.SkywalkingConfig, apiMap map[string]apmapi.QueryByApmApi, timeout int64) {
-	if conf == nil {
-		log.Printf(INVALID_API, "SkywalkingApi", "skywalking")
-		return
-	}
-	if len(conf.Address) == 0 {
-		log.Printf(INVALID_API, "SkywalkingApi", "skywalking.address")
-		return
-	}
-	log.Printf(VALID_API, "skywalking")
-	apiMap[APMTYPE_SW] = skywalking.NewSkywalkingApi(conf.Address, conf.User, conf.Password, timeout)
-}
-
-func buildJaegerApi(conf *config.JaegerConfig, apiMap map[string]apmapi.QueryByApmApi, timeout int64) {
-	if conf == nil {
-		log.Printf(INVALID_API, "JaegerApi", "jaeger")
-		return
-	}
-	if len(conf.Address) == 0 {
-		log.Printf(INVALID_API, "JaegerApi", "jaeger.address")
-		return
-	}
-	log.Printf(VALID_API, "jaeger")
-	apiMap[APMTYPE_OTEL] = jaeger.NewJaegerApi(conf.Address, timeout)
+func (client *ApmTraceClient) QueryTraceList(apmType string, traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
+	services, _, err := client.QueryPartialTraceList(apmType, traceId, startTimeMs, attributes, apmapi.QueryOptions{})
+	return services, err
 }
 
-func buildEsapmApi(conf *config.ElasticConfig, apiMap map[string]apmapi.QueryByApmApi, timeout int64) {
-	if conf == nil {
-		log.Printf(INVALID_API, "elasticApi", "elastic")
-		return
+// QueryPartialTraceList is QueryTraceList which reports whether the trace is complete, the backends implementing
+// apmapi.PartialQueryApi return the received part of an incomplete trace unless the query is strict.
+func (client *ApmTraceClient) QueryPartialTraceList(apmType string, traceId string, startTimeMs int64, attributes string, opts apmapi.QueryOptions) ([]*model.OtelServiceNode, bool, error) {
+	api, exist := client.apiMap[apmType]
+	if !exist {
+		return nil, false, apmapi.NewBadRequestError("unknown apmType: %s", apmType)
 	}
-	if len(conf.Address) == 0 {
-		log.Printf(INVALID_API, "elasticApi", "elastic")
-		return
+	var (
+		services []*model.OtelServiceNode
+		complete = true
+		err      error
+	)
+	opts.ClockSkew = client.clockSkew
+	if partialApi, ok := api.(apmapi.PartialQueryApi); ok {
+		services, complete, err = partialApi.QueryPartialList(traceId, startTimeMs, attributes, opts)
+	} else if optionsApi, ok := api.(apmapi.OptionsQueryApi); ok {
+		services, err = optionsApi.QueryListWithOptions(traceId, startTimeMs, attributes, opts)
+	} else {
+		services, err = api.QueryList(traceId, startTimeMs, attributes)
 	}
-
-	esAPMClient, err := elastic.NewELASTICApi(conf.Address, conf.User, conf.Password, timeout)
 	if err != nil {
-		log.Printf("[x Build elasticApi] %v", err)
-		return
+		// The request errors are typed by the backends, an untyped error is raised by converting the response.
+		return nil, false, apmapi.WrapConversionError(client.backendMap[apmType].Name, err)
 	}
-	log.Printf(VALID_API, "elastic")
-	apiMap[APMTYPE_ELASTIC] = esAPMClient
+	client.processServices(client.backendMap[apmType].Name, services)
+	return services, complete, nil
 }
 
-func buildPinpointApi(conf *config.PinpointConfig, apiMap map[string]apmapi.QueryByApmApi, timeout int64) {
-	if conf == nil {
-		log.Printf(INVALID_API, "pinpointApi", "pinpoint")
-		return
-	}
-	if len(conf.Address) == 0 {
-		log.Printf(INVALID_API, "pinpointApi", "pinpoint")
-		return
+// newClockSkewAdjuster returns nil if the adjustment is not enabled.
+func newClockSkewAdjuster(conf *config.ClockSkewConfig) *apmapi.ClockSkewAdjuster {
+	if conf == nil || !conf.Enabled {
+		return nil
 	}
-
-	ppAPMClient, err := pinpoint.NewPinpointApi(conf.Address, timeout)
-	if err != nil {
-		log.Printf("[x Build pinpointApi] %v", err)
-		return
-	}
-	log.Printf(VALID_API, "pinpoint")
-	apiMap[APMTYPE_PINPOINT] = ppAPMClient
+	return apmapi.NewClockSkewAdjuster(time.Duration(conf.MaxAdjustmentMs) * time.Millisecond)
 }
 
-func (client *ApmTraceClient) QueryTraceList(apmType string, traceId string, startTimeMs int64, attributes string) ([]*model.OtelServiceNode, error) {
-	if api, exist := client.apiMap[apmType]; exist {
-		return api.QueryList(traceId, startTimeMs, attributes)
-	}
-	return nil, fmt.Errorf("unknown apmType: %s", apmType)
+// processServices rewrites the converted spans before they are returned, the keys are normalized before the redaction rules match them.
+func (client *ApmTraceClient) processServices(backendName string, services []*model.OtelServiceNode) {
+	client.normalizer.NormalizeServices(services)
+	client.redactor.RedactServices(backendName, services)
 }
@@ -0,0 +1,198 @@
+package apmtrace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+)
+
+const testdataDir = "apmapi/testdata/tracelist/"
+
+// skewedServerSpanId is the server span of the http case whose clock is moved 500ms behind its client span.
+const skewedServerSpanId = "3073e355ddc1b8f6"
+
+func addSkewedJaegerTrace(t *testing.T, server *apmtest.FakeServer) string {
+	t.Helper()
+	data, err := os.ReadFile(testdataDir + "jaeger/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response map[string]any
+	if err = json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	trace := response["data"].([]any)[0].(map[string]any)
+	for _, span := range trace["spans"].([]any) {
+		span := span.(map[string]any)
+		if span["processID"] == "p2" {
+			span["startTime"] = span["startTime"].(float64) - 500000
+		}
+	}
+	if data, err = json.Marshal(response); err != nil {
+		t.Fatal(err)
+	}
+	traceId := trace["traceID"].(string)
+	server.AddTrace(traceId, data)
+	return traceId
+}
+
+func findSpan(services []*model.OtelServiceNode, spanId string) *model.OtelSpan {
+	for _, service := range services {
+		for _, spans := range [][]*model.OtelSpan{service.EntrySpans, service.ExitSpans} {
+			for _, span := range spans {
+				if span.SpanId == spanId {
+					return span
+				}
+			}
+		}
+		if span := findSpan(service.Children, spanId); span != nil {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestClockSkewQuery(t *testing.T) {
+	server := apmtest.NewJaegerServer()
+	defer server.Close()
+	traceId := addSkewedJaegerTrace(t, server)
+
+	newClient := func(clockSkew *config.ClockSkewConfig) *ApmTraceClient {
+		client, err := NewApmTraceClient(&config.TraceApiConfig{
+			ApmList:   []string{"jaeger"},
+			ClockSkew: clockSkew,
+			Backends:  map[string]any{"jaeger": map[string]any{"address": server.Address()}},
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	client := newClient(&config.ClockSkewConfig{Enabled: true})
+	// Another client must not change the adjustment of the existing one.
+	disabledClient := newClient(nil)
+
+	services, err := client.QueryTraceList(jaeger.ApmType, traceId, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := findSpan(services, skewedServerSpanId)
+	if span == nil {
+		t.Fatalf("[Check span] %s is not found", skewedServerSpanId)
+	}
+	if _, exist := span.Attributes[apmapi.AttributeClockSkewOffset]; !exist {
+		t.Errorf("[Check adjusted] want %s on the server span", apmapi.AttributeClockSkewOffset)
+	}
+
+	services, err = disabledClient.QueryTraceList(jaeger.ApmType, traceId, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, exist := findSpan(services, skewedServerSpanId).Attributes[apmapi.AttributeClockSkewOffset]; exist {
+		t.Errorf("[Check disabled] want no offset, got=%s", offset)
+	}
+
+	dir := t.TempDir()
+	if _, _, err = client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, &RecordOptions{Dir: dir, Case: "skew"}); err != nil {
+		t.Fatal(err)
+	}
+	validate, err := os.ReadFile(filepath.Join(dir, "jaeger", "skew", "validate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(validate), apmapi.AttributeClockSkewOffset) {
+		t.Errorf("[Check record] want the fixture converted without the adjustment")
+	}
+}
+
+func TestRecordTraceListMapping(t *testing.T) {
+	server := apmtest.NewJaegerServer()
+	defer server.Close()
+	traceId, err := server.AddFixture(testdataDir + "jaeger/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewApmTraceClient(&config.TraceApiConfig{
+		ApmList: []string{"jaeger"},
+		Backends: map[string]any{"jaeger": map[string]any{
+			"address": server.Address(),
+			"attribute_mapping": []any{
+				map[string]any{"key": "http.route", "action": "rename", "target": "custom.route"},
+			},
+		}},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	services, complete, err := client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, &RecordOptions{Dir: dir, Case: "mapping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete {
+		t.Errorf("[Check complete] want=true, got=false")
+	}
+	reply, err := json.Marshal(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(reply), "custom.route") {
+		t.Errorf("[Check reply] want converted with the attribute_mapping of the config")
+	}
+	validate, err := os.ReadFile(filepath.Join(dir, "jaeger", "mapping", "validate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(validate), "custom.route") {
+		t.Errorf("[Check record] want the fixture converted with the default mapping")
+	}
+}
+
+func TestRecordTraceListErrors(t *testing.T) {
+	server := apmtest.NewJaegerServer()
+	defer server.Close()
+	traceId, err := server.AddFixture(testdataDir + "jaeger/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewApmTraceClient(&config.TraceApiConfig{
+		ApmList:  []string{"jaeger"},
+		Backends: map[string]any{"jaeger": map[string]any{"address": server.Address()}},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	record := &RecordOptions{Dir: dir, Case: "http"}
+	if _, _, err = client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, record); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, record)
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeBadRequest {
+		t.Errorf("[Check existing case] want=%s, got=%s (%v)", apmapi.ErrCodeBadRequest, got, err)
+	}
+	record.Overwrite = true
+	if _, _, err = client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, record); err != nil {
+		t.Errorf("[Check overwrite] want=nil, got=%v", err)
+	}
+
+	server.SetFault(apmtest.Fault{Body: []byte(`{"truncated`)})
+	defer server.SetFault(apmtest.Fault{})
+	_, _, err = client.RecordTraceList(jaeger.ApmType, traceId, 0, "", apmapi.QueryOptions{}, &RecordOptions{Dir: dir, Case: "malformed"})
+	if got := apmapi.GetErrorCode(err); got != apmapi.ErrCodeMalformedResponse {
+		t.Errorf("[Check malformed] want=%s, got=%s (%v)", apmapi.ErrCodeMalformedResponse, got, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "jaeger", "malformed")); !os.IsNotExist(err) {
+		t.Errorf("[Check malformed] want no fixture, got err=%v", err)
+	}
+}
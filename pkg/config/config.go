@@ -1,5 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/mitchellh/mapstructure"
+)
+
 type AdapterConfig struct {
 	HttpPort int             `mapstructure:"http_port"`
 	Timeout  int64           `mapstructure:"timeout"`
@@ -7,29 +16,103 @@ type AdapterConfig struct {
 }
 
 type TraceApiConfig struct {
-	ApmList    []string          `mapstructure:"apm_list"`
-	Skywalking *SkywalkingConfig `mapstructure:"skywalking"`
-	Jaeger     *JaegerConfig     `mapstructure:"jaeger"`
-	Elastic    *ElasticConfig    `mapstructure:"elastic"`
-	Pinpoint   *PinpointConfig   `mapstructure:"pinpoint"`
+	ApmList []string `mapstructure:"apm_list"`
+	// Redaction scrubs the converted spans before they are returned, nothing is changed if it is not set.
+	Redaction *RedactionConfig `mapstructure:"redaction"`
+	// SemConv rewrites the attributes of all backends to one version of the OpenTelemetry semantic conventions, nothing is changed if it is not set.
+	SemConv *SemConvConfig `mapstructure:"semconv"`
+	// ClockSkew shifts the server spans which do not fit in their client spans, nothing is changed if it is not set.
+	ClockSkew *ClockSkewConfig `mapstructure:"clock_skew"`
+	// Backends keeps the raw section of each backend, eg. skywalking, jaeger, which is decoded by the registered apmapi.Backend.
+	Backends map[string]any `mapstructure:",remain"`
+}
+
+// SemConvConfig selects the keys of the attributes which are named differently across the versions, eg. http.url and url.full.
+type SemConvConfig struct {
+	// Version is the target version, eg. 1.20.0 emits http.url and 1.26.0 emits url.full and db.query.text.
+	Version string `mapstructure:"version"`
+	// DualEmit sets both the old and the new keys, eg. while the downstream rules are migrated.
+	DualEmit bool `mapstructure:"dual_emit"`
+}
+
+// ParseVersion returns the major and minor of Version, the patch is ignored as the keys only change in minor versions.
+func (cfg *SemConvConfig) ParseVersion() (major int, minor int, err error) {
+	parts := strings.Split(strings.TrimPrefix(cfg.Version, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, fmt.Errorf("version must be in major.minor[.patch] form, got %q", cfg.Version)
+	}
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		if numbers[i], err = strconv.Atoi(part); err != nil || numbers[i] < 0 {
+			return 0, 0, fmt.Errorf("version must be in major.minor[.patch] form, got %q", cfg.Version)
+		}
+	}
+	if numbers[0] != 1 {
+		return 0, 0, fmt.Errorf("version %s is not supported, only 1.x is supported", cfg.Version)
+	}
+	return numbers[0], numbers[1], nil
 }
 
-type SkywalkingConfig struct {
-	Address  string `mapstructure:"address"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+// ClockSkewConfig corrects the skew between the clocks of the hosts, the offset is applied to the subtree of the server span
+// and recorded in apo.clock_skew.offset.
+type ClockSkewConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxAdjustmentMs leaves the larger skews as they are, 0 does not limit the offset.
+	MaxAdjustmentMs int64 `mapstructure:"max_adjustment_ms"`
 }
 
-type JaegerConfig struct {
-	Address string `mapstructure:"address"`
+// RedactionConfig is applied to the spans of every backend, rules with apm_types only apply to the listed backends.
+type RedactionConfig struct {
+	// ObfuscateSQL replaces the literals of db.statement with ?, eg. where id = 1 -> where id = ?, the bind values in db.statement.parameters are dropped.
+	ObfuscateSQL bool `mapstructure:"obfuscate_sql"`
+	// StripURLQuery removes the query of URL attributes and URL span names, only URLQueryParams are removed if it is set.
+	StripURLQuery  bool     `mapstructure:"strip_url_query"`
+	URLQueryParams []string `mapstructure:"url_query_params"`
+
+	MaskRules        []MaskRule        `mapstructure:"mask_rules"`
+	AttributeFilters []AttributeFilter `mapstructure:"attribute_filters"`
 }
 
-type ElasticConfig struct {
-	Address  string `mapstructure:"address"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+// MaskRule replaces the matches of Pattern in the listed attributes, exception.message and exception.stacktrace address the exceptions.
+// Attributes are glob patterns, eg. http.request.header.*, all attributes and exceptions are masked if it is empty.
+// The attributes of the span events are masked by the same rules, the internal apm.* and apo.* attributes are not masked.
+type MaskRule struct {
+	ApmTypes    []string `mapstructure:"apm_types"`
+	Attributes  []string `mapstructure:"attributes"`
+	Pattern     string   `mapstructure:"pattern"`
+	Replacement string   `mapstructure:"replacement"`
 }
 
-type PinpointConfig struct {
-	Address string `mapstructure:"address"`
+// AttributeFilter keeps only the Allow attributes if it is set and drops the Deny attributes, both are glob patterns.
+// The filters apply to the attributes of the span events too, the internal apm.* and apo.* attributes are always kept.
+type AttributeFilter struct {
+	ApmTypes []string `mapstructure:"apm_types"`
+	Allow    []string `mapstructure:"allow"`
+	Deny     []string `mapstructure:"deny"`
+}
+
+// DecodeBackend decodes the section of a registered backend into its config, nil is returned if the section is not set.
+func (cfg *TraceApiConfig) DecodeBackend(name string) (any, error) {
+	backend, exist := apmapi.GetBackend(name)
+	if !exist {
+		return nil, fmt.Errorf("unknown apmType %s", name)
+	}
+	section, exist := cfg.Backends[name]
+	if !exist || section == nil {
+		return nil, nil
+	}
+
+	backendCfg := backend.NewConfig()
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.StringToSliceHookFunc(","),
+		Result:           backendCfg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = decoder.Decode(section); err != nil {
+		return nil, fmt.Errorf("fail to decode adapter.trace_api.%s: %w", name, err)
+	}
+	return backendCfg, nil
 }
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/spf13/viper"
+)
+
+const (
+	// EnvPrefix is prepended to every config key, eg. adapter.trace_api.skywalking.password -> APO_ADAPTER_TRACE_API_SKYWALKING_PASSWORD.
+	EnvPrefix = "APO_ADAPTER_"
+	// FileSuffix reads the value from a mounted file, eg. APO_ADAPTER_TRACE_API_SKYWALKING_PASSWORD_FILE or skywalking.password_file.
+	FileSuffix = "_file"
+
+	redactedValue = "******"
+)
+
+type configKey struct {
+	Key    string
+	Secret bool
+}
+
+// BindEnv binds every key of AdapterConfig and registered backends under root to its APO_ADAPTER_ env and resolves the *_file variants.
+func BindEnv(v *viper.Viper, root string) error {
+	for _, key := range listAllConfigKeys() {
+		fullKey := root + "." + key.Key
+		envName := GetEnvName(key.Key)
+		if err := v.BindEnv(fullKey, envName); err != nil {
+			return err
+		}
+
+		filePath := os.Getenv(envName + strings.ToUpper(FileSuffix))
+		if filePath == "" {
+			filePath = v.GetString(fullKey + FileSuffix)
+		}
+		if filePath == "" {
+			continue
+		}
+		if _, exist := os.LookupEnv(envName); exist {
+			return fmt.Errorf("both %s and %s%s are set", envName, envName, strings.ToUpper(FileSuffix))
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("fail to read %s%s: %w", fullKey, FileSuffix, err)
+		}
+		v.Set(fullKey, strings.TrimRight(string(content), "\r\n"))
+	}
+	return nil
+}
+
+func GetEnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ListConfigKeys returns the dotted keys of all leaf fields in AdapterConfig and the config of registered backends.
+func ListConfigKeys() []string {
+	keys := make([]string, 0)
+	for _, key := range listAllConfigKeys() {
+		keys = append(keys, key.Key)
+	}
+	return keys
+}
+
+func listAllConfigKeys() []configKey {
+	keys := listConfigKeys(reflect.TypeOf(AdapterConfig{}), "")
+	for _, name := range apmapi.ListBackends() {
+		backend, _ := apmapi.GetBackend(name)
+		keys = append(keys, listConfigKeys(reflect.TypeOf(backend.NewConfig()), "trace_api."+name+".")...)
+	}
+	return keys
+}
+
+func getTagName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func listConfigKeys(t reflect.Type, prefix string) []configKey {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	keys := make([]configKey, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := getTagName(field)
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			keys = append(keys, listConfigKeys(fieldType, key+".")...)
+		} else {
+			keys = append(keys, configKey{Key: key, Secret: field.Tag.Get("secret") == "true"})
+		}
+	}
+	return keys
+}
+
+// RedactedString prints the config as json with all secret fields masked, it is safe for logging.
+func RedactedString(cfg any) string {
+	data, err := json.Marshal(redact(reflect.ValueOf(cfg)))
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// String prints the config with the backend sections decoded by the registry, so their secret fields are masked.
+// Sections of unknown backends are masked entirely.
+func (cfg *AdapterConfig) String() string {
+	result, ok := redact(reflect.ValueOf(cfg)).(map[string]any)
+	if !ok || cfg.TraceApi == nil {
+		return RedactedString(cfg)
+	}
+	traceApi := result["trace_api"].(map[string]any)
+	for name := range cfg.TraceApi.Backends {
+		if backendCfg, err := cfg.TraceApi.DecodeBackend(name); err == nil {
+			traceApi[name] = redact(reflect.ValueOf(backendCfg))
+		} else {
+			traceApi[name] = redactedValue
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func redact(value reflect.Value) any {
+	if !value.IsValid() {
+		return nil
+	}
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return value.Interface()
+	}
+
+	result := make(map[string]any)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := getTagName(field)
+		if name == "" {
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			if !value.Field(i).IsZero() {
+				result[name] = redactedValue
+			} else {
+				result[name] = ""
+			}
+			continue
+		}
+		result[name] = redact(value.Field(i))
+	}
+	return result
+}
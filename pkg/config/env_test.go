@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+	. "github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/spf13/viper"
+)
+
+func TestBindEnv(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APO_ADAPTER_HTTP_PORT", "9000")
+	t.Setenv("APO_ADAPTER_TRACE_API_SKYWALKING_ADDRESS", "oap:12800")
+	t.Setenv("APO_ADAPTER_TRACE_API_SKYWALKING_PASSWORD_FILE", secretFile)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader("adapter:\n  http_port: 8079\n  trace_api:\n    apm_list: [skywalking]\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := BindEnv(v, "adapter"); err != nil {
+		t.Fatal(err)
+	}
+	wrapper := struct {
+		Adapter AdapterConfig `mapstructure:"adapter"`
+	}{}
+	if err := v.Unmarshal(&wrapper); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := wrapper.Adapter
+	if cfg.HttpPort != 9000 {
+		t.Errorf("[Check http_port] want=9000, got=%d", cfg.HttpPort)
+	}
+	backendCfg, err := cfg.TraceApi.DecodeBackend(skywalking.BackendName)
+	if err != nil || backendCfg == nil {
+		t.Fatalf("[Check skywalking] fail to decode: %v", err)
+	}
+	swCfg := backendCfg.(*skywalking.Config)
+	if swCfg.Address != "oap:12800" {
+		t.Errorf("[Check skywalking.address] want=oap:12800, got=%s", swCfg.Address)
+	}
+	if swCfg.Password != "from-file" {
+		t.Errorf("[Check skywalking.password] want=from-file, got=%s", swCfg.Password)
+	}
+	if strings.Contains(cfg.String(), "from-file") {
+		t.Errorf("[Check redacted] secret is printed: %s", cfg.String())
+	}
+}
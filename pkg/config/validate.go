@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+)
+
+const (
+	maxTimeoutSeconds = 600
+)
+
+var (
+	ErrNoApmList = errors.New("adapter.trace_api.apm_list is empty")
+)
+
+// CheckUnknownKeys reports every key in settings which is not declared in AdapterConfig, such as typo `skywalkng`.
+func CheckUnknownKeys(settings map[string]any, root string) error {
+	knownKeys := make(map[string]bool)
+	for _, key := range ListConfigKeys() {
+		knownKeys[root+"."+key] = true
+		knownKeys[root+"."+key+FileSuffix] = true
+		// Sections may be declared empty, eg. `pinpoint:`
+		for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+			knownKeys[root+"."+key[:i]] = true
+		}
+	}
+	knownKeys[root] = true
+
+	unknownKeys := make([]string, 0)
+	for _, key := range flattenKeys(settings, "") {
+		if !knownKeys[key] {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	if len(unknownKeys) == 0 {
+		return nil
+	}
+	sort.Strings(unknownKeys)
+
+	errs := make([]error, 0, len(unknownKeys))
+	for _, key := range unknownKeys {
+		errs = append(errs, fmt.Errorf("unknown key: %s", key))
+	}
+	return errors.Join(errs...)
+}
+
+func flattenKeys(settings map[string]any, prefix string) []string {
+	keys := make([]string, 0)
+	for key, value := range settings {
+		if child, ok := value.(map[string]any); ok && len(child) > 0 {
+			keys = append(keys, flattenKeys(child, prefix+key+".")...)
+		} else {
+			keys = append(keys, prefix+key)
+		}
+	}
+	return keys
+}
+
+// Validate checks the required fields of every backend in apm_list and the value ranges, all problems are reported together.
+func (cfg *AdapterConfig) Validate() error {
+	errs := make([]error, 0)
+	if cfg.HttpPort < 1 || cfg.HttpPort > 65535 {
+		errs = append(errs, fmt.Errorf("adapter.http_port must be in [1, 65535], got %d", cfg.HttpPort))
+	}
+	if cfg.Timeout <= 0 || cfg.Timeout > maxTimeoutSeconds {
+		errs = append(errs, fmt.Errorf("adapter.timeout must be in [1, %d] seconds, got %d", maxTimeoutSeconds, cfg.Timeout))
+	}
+	if cfg.TraceApi == nil {
+		errs = append(errs, errors.New("adapter.trace_api is not set"))
+	} else {
+		errs = append(errs, cfg.TraceApi.validate()...)
+	}
+	return errors.Join(errs...)
+}
+
+func (cfg *TraceApiConfig) validate() []error {
+	if len(cfg.ApmList) == 0 {
+		return []error{ErrNoApmList}
+	}
+
+	errs := make([]error, 0)
+	listed := make(map[string]bool)
+	for _, apmType := range cfg.ApmList {
+		if listed[apmType] {
+			errs = append(errs, fmt.Errorf("adapter.trace_api.apm_list: %s is duplicated", apmType))
+			continue
+		}
+		listed[apmType] = true
+
+		if _, exist := apmapi.GetBackend(apmType); !exist {
+			errs = append(errs, fmt.Errorf("adapter.trace_api.apm_list: unknown apmType %s, supported: %v", apmType, apmapi.ListBackends()))
+			continue
+		}
+		backendCfg, err := cfg.DecodeBackend(apmType)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if backendCfg == nil {
+			errs = append(errs, fmt.Errorf("adapter.trace_api.%s is required as %s is in apm_list", apmType, apmType))
+			continue
+		}
+		if validator, ok := backendCfg.(apmapi.ConfigValidator); ok {
+			errs = appendErr(errs, validator.Validate("adapter.trace_api."+apmType))
+		}
+	}
+	if cfg.SemConv != nil {
+		if _, _, err := cfg.SemConv.ParseVersion(); err != nil {
+			errs = append(errs, fmt.Errorf("adapter.trace_api.semconv.%v", err))
+		}
+	}
+	if cfg.Redaction != nil {
+		errs = append(errs, cfg.Redaction.validate("adapter.trace_api.redaction")...)
+	}
+	if cfg.ClockSkew != nil && cfg.ClockSkew.MaxAdjustmentMs < 0 {
+		errs = append(errs, fmt.Errorf("adapter.trace_api.clock_skew.max_adjustment_ms must not be negative, got %d", cfg.ClockSkew.MaxAdjustmentMs))
+	}
+	return errs
+}
+
+func (cfg *RedactionConfig) validate(prefix string) []error {
+	errs := make([]error, 0)
+	for i, rule := range cfg.MaskRules {
+		key := fmt.Sprintf("%s.mask_rules[%d]", prefix, i)
+		if rule.Pattern == "" {
+			errs = append(errs, fmt.Errorf("%s.pattern is required", key))
+		} else if _, err := regexp.Compile(rule.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s.pattern is invalid: %v", key, err))
+		}
+		errs = append(errs, validateApmTypes(key+".apm_types", rule.ApmTypes)...)
+		errs = append(errs, validateGlobs(key+".attributes", rule.Attributes)...)
+	}
+	for i, filter := range cfg.AttributeFilters {
+		key := fmt.Sprintf("%s.attribute_filters[%d]", prefix, i)
+		if len(filter.Allow) == 0 && len(filter.Deny) == 0 {
+			errs = append(errs, fmt.Errorf("%s requires allow or deny", key))
+		}
+		errs = append(errs, validateApmTypes(key+".apm_types", filter.ApmTypes)...)
+		errs = append(errs, validateGlobs(key+".allow", filter.Allow)...)
+		errs = append(errs, validateGlobs(key+".deny", filter.Deny)...)
+	}
+	return errs
+}
+
+func validateApmTypes(key string, apmTypes []string) []error {
+	errs := make([]error, 0)
+	for _, apmType := range apmTypes {
+		if _, exist := apmapi.GetBackend(apmType); !exist {
+			errs = append(errs, fmt.Errorf("%s: unknown apmType %s, supported: %v", key, apmType, apmapi.ListBackends()))
+		}
+	}
+	return errs
+}
+
+func validateGlobs(key string, patterns []string) []error {
+	errs := make([]error, 0)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid pattern %s", key, pattern))
+		}
+	}
+	return errs
+}
+
+func appendErr(errs []error, err error) []error {
+	if err != nil {
+		return append(errs, err)
+	}
+	return errs
+}
+
+// ValidateHostAddress checks address in host:port[/path] form, the scheme is added by the backend.
+func ValidateHostAddress(key string, address string) error {
+	if address == "" {
+		return fmt.Errorf("%s is required", key)
+	}
+	if strings.Contains(address, "://") {
+		return fmt.Errorf("%s must not contain scheme, got %s", key, address)
+	}
+	return ValidateURLAddress(key, address)
+}
+
+// ValidateURLAddress checks address in [scheme://]host[:port][/path] form.
+func ValidateURLAddress(key string, address string) error {
+	if address == "" {
+		return fmt.Errorf("%s is required", key)
+	}
+	rawURL := address
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		if strings.Contains(address, "://") {
+			return fmt.Errorf("%s only supports http or https, got %s", key, address)
+		}
+		rawURL = "http://" + address
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%s is invalid: %v", key, err)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("%s has no host, got %s", key, address)
+	}
+	if port := parsed.Port(); port != "" {
+		portNum, err := strconv.Atoi(port)
+		if err != nil || portNum < 1 || portNum > 65535 {
+			return fmt.Errorf("%s has invalid port %s", key, port)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/elastic"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/pinpoint"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/remote"
+	_ "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+	. "github.com/CloudDetail/apo-apm-adapter/pkg/config"
+)
+
+func TestCheckUnknownKeys(t *testing.T) {
+	settings := map[string]any{
+		"adapter": map[string]any{
+			"http_port": 8079,
+			"trace_api": map[string]any{
+				"apm_list": []any{"skywalking"},
+				"skywalking": map[string]any{
+					"address":       "oap:12800",
+					"password_file": "/etc/secret",
+				},
+				"skywalkng": map[string]any{"address": "oap:12800"},
+				"pinpoint":  nil,
+			},
+		},
+	}
+	err := CheckUnknownKeys(settings, "adapter")
+	if err == nil {
+		t.Fatal("[Check unknown keys] want error, got nil")
+	}
+	if got := err.Error(); got != "unknown key: adapter.trace_api.skywalkng.address" {
+		t.Errorf("[Check unknown keys] got=%s", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &AdapterConfig{
+		HttpPort: 8079,
+		Timeout:  10,
+		TraceApi: &TraceApiConfig{
+			ApmList: []string{"skywalking", "elastic"},
+			Backends: map[string]any{
+				"skywalking": map[string]any{"address": "oap:12800"},
+				"elastic":    map[string]any{"address": "https://es:9200"},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("[Check valid config] got=%v", err)
+	}
+
+	cfg.TraceApi.Redaction = &RedactionConfig{
+		ObfuscateSQL: true,
+		MaskRules: []MaskRule{
+			{Attributes: []string{"exception.*"}, Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`},
+		},
+		AttributeFilters: []AttributeFilter{
+			{ApmTypes: []string{"elastic"}, Deny: []string{"http.request.header.*"}},
+		},
+	}
+	cfg.TraceApi.SemConv = &SemConvConfig{Version: "1.26.0", DualEmit: true}
+	cfg.TraceApi.ClockSkew = &ClockSkewConfig{Enabled: true, MaxAdjustmentMs: 1000}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("[Check valid redaction, semconv and clock_skew] got=%v", err)
+	}
+
+	cfg.Timeout = 0
+	cfg.TraceApi.SemConv.Version = "1.x"
+	cfg.TraceApi.ClockSkew.MaxAdjustmentMs = -1
+	cfg.TraceApi.Redaction.MaskRules = append(cfg.TraceApi.Redaction.MaskRules, MaskRule{Pattern: "(unclosed"})
+	cfg.TraceApi.Redaction.AttributeFilters = append(cfg.TraceApi.Redaction.AttributeFilters, AttributeFilter{ApmTypes: []string{"zipkin"}, Allow: []string{"[a-"}})
+	cfg.TraceApi.ApmList = append(cfg.TraceApi.ApmList, "jaeger", "zipkin", "remote")
+	cfg.TraceApi.Backends["remote"] = map[string]any{"address": "http://plugin:8080", "transport": "grpc"}
+	cfg.TraceApi.Backends["skywalking"] = map[string]any{
+		"address": "oap:99999",
+		"attribute_mapping": []any{
+			map[string]any{"key": "plugin.sql", "action": "rename", "target": "db.statement"},
+			map[string]any{"key": "plugin.*", "action": "move"},
+		},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("[Check invalid config] want error, got nil")
+	}
+	for _, expect := range []string{
+		"adapter.timeout",
+		"adapter.trace_api.skywalking.address has invalid port 99999",
+		"adapter.trace_api.jaeger is required",
+		"adapter.trace_api.remote.transport only supports http, got grpc",
+		"unknown apmType zipkin",
+		"adapter.trace_api.redaction.mask_rules[1].pattern is invalid",
+		"adapter.trace_api.redaction.attribute_filters[1].apm_types: unknown apmType zipkin",
+		"adapter.trace_api.redaction.attribute_filters[1].allow: invalid pattern [a-",
+		"adapter.trace_api.skywalking.attribute_mapping[1].action: unknown action move",
+		"adapter.trace_api.semconv.version must be in major.minor[.patch] form",
+		"adapter.trace_api.clock_skew.max_adjustment_ms must not be negative",
+	} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("[Check invalid config] want=%s, got=%v", expect, err)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package global
 
-import "github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+import (
+	"sync/atomic"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+)
 
 var (
-	TRACE_CLIENT *apmtrace.ApmTraceClient
+	// TRACE_CLIENT is swapped atomically on config reload, requests in flight keep the client they loaded.
+	TRACE_CLIENT atomic.Pointer[apmtrace.ApmTraceClient]
+	// RECORD_DIR enables recording requests with X-Apo-Record header, it is empty by default.
+	RECORD_DIR string
 )
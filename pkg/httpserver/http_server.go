@@ -2,13 +2,18 @@ package httpserver
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"strconv"
 	"syscall"
 
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
 	"github.com/CloudDetail/apo-apm-adapter/pkg/global"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
 
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/pprof"
@@ -16,8 +21,7 @@ import (
 
 func StartHttpServer(port int) {
 	app := iris.Default()
-
-	app.Post("/trace/list", queryTraceList)
+	registerRoutes(app)
 
 	p := pprof.New()
 	app.Any("/debug/pprof", p)
@@ -38,6 +42,25 @@ func StartHttpServer(port int) {
 	}
 }
 
+func registerRoutes(app *iris.Application) {
+	app.Use(recoverConversion)
+
+	app.Post("/trace/list", queryTraceList)
+	app.Get("/healthz", healthz)
+	app.Get("/readyz", readyz)
+}
+
+const (
+	// HeaderRecord names the fixture case to record the request, it is ignored unless the server is started with -record-dir.
+	HeaderRecord          = "X-Apo-Record"
+	HeaderRecordAnonymize = "X-Apo-Record-Anonymize"
+	// HeaderRecordOverwrite replaces an existing case, the request fails with BAD_REQUEST otherwise.
+	HeaderRecordOverwrite = "X-Apo-Record-Overwrite"
+
+	contextTraceId = "traceId"
+	contextApmType = "apmType"
+)
+
 type BasicStatus string
 
 const (
@@ -54,34 +77,117 @@ type BasicResponse struct {
 func queryTraceList(ctx iris.Context) {
 	var request TraceListRequest
 	if err := ctx.ReadJSON(&request); err != nil {
-		responseWithError(ctx, err)
+		responseWithError(ctx, apmapi.WrapBadRequest(err))
 		return
 	}
 
-	result, err := global.TRACE_CLIENT.QueryTraceList(request.ApmType, request.TraceId, request.StartTime, request.Attributes)
+	ctx.Values().Set(contextTraceId, request.TraceId)
+	ctx.Values().Set(contextApmType, request.ApmType)
+
+	var (
+		result   []*model.OtelServiceNode
+		complete bool
+		err      error
+	)
+	traceClient := global.TRACE_CLIENT.Load()
+	opts := apmapi.QueryOptions{
+		Strict: request.Strict,
+	}
+	if recordCase := ctx.GetHeader(HeaderRecord); recordCase != "" && global.RECORD_DIR != "" {
+		result, complete, err = traceClient.RecordTraceList(request.ApmType, request.TraceId, request.StartTime, request.Attributes, opts, &apmtrace.RecordOptions{
+			Dir:       global.RECORD_DIR,
+			Case:      recordCase,
+			Anonymize: ctx.GetHeader(HeaderRecordAnonymize) == "true",
+			Overwrite: ctx.GetHeader(HeaderRecordOverwrite) == "true",
+		})
+	} else {
+		result, complete, err = traceClient.QueryPartialTraceList(request.ApmType, request.TraceId, request.StartTime, request.Attributes, opts)
+	}
 	if err != nil {
 		log.Printf("[QueryTraceList] apmType: %s, traceId: %s, error: %v", request.ApmType, request.TraceId, err)
 		responseWithError(ctx, err)
 		return
 	}
-	log.Printf("[QueryTraceList] apmType: %s, traceId: %s, size: %d", request.ApmType, request.TraceId, len(result))
+	log.Printf("[QueryTraceList] apmType: %s, traceId: %s, size: %d, complete: %v", request.ApmType, request.TraceId, len(result), complete)
+	ctx.JSON(iris.Map{
+		"success":    true,
+		"data":       result,
+		"incomplete": !complete,
+	})
+}
+
+// recoverConversion reports the panic of a malformed trace with its traceId, the server keeps serving other requests.
+func recoverConversion(ctx iris.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			traceId := ctx.Values().GetString(contextTraceId)
+			apmType := ctx.Values().GetString(contextApmType)
+			log.Printf("[x Panic] apmType: %s, traceId: %s, panic: %v\n%s", apmType, traceId, r, debug.Stack())
+			if !ctx.IsStopped() {
+				responseWithError(ctx, fmt.Errorf("[x Panic] fail to convert apmType: %s, traceId: %s: %v", apmType, traceId, r))
+			}
+		}
+	}()
+	ctx.Next()
+}
+
+func healthz(ctx iris.Context) {
 	ctx.JSON(iris.Map{
-		"success": true,
-		"data":    result,
+		"status": "ok",
 	})
 }
 
+func readyz(ctx iris.Context) {
+	traceClient := global.TRACE_CLIENT.Load()
+	if traceClient == nil {
+		ctx.StatusCode(iris.StatusServiceUnavailable)
+		ctx.JSON(iris.Map{
+			"ready": false,
+		})
+		return
+	}
+
+	report := traceClient.CheckHealth()
+	if !report.Ready {
+		ctx.StatusCode(iris.StatusServiceUnavailable)
+	}
+	ctx.JSON(report)
+}
+
 func responseWithError(ctx iris.Context, err error) {
-	ctx.StopWithStatus(iris.StatusInternalServerError)
+	errorCode := apmapi.GetErrorCode(err)
+	ctx.StopWithStatus(getHttpStatus(errorCode))
 	ctx.JSON(iris.Map{
-		"success":  false,
-		"errorMsg": err.Error(),
+		"success":   false,
+		"errorCode": errorCode,
+		"errorMsg":  err.Error(),
 	})
 }
 
+func getHttpStatus(errorCode apmapi.ErrorCode) int {
+	switch errorCode {
+	case apmapi.ErrCodeBadRequest:
+		return iris.StatusBadRequest
+	case apmapi.ErrCodeUnauthorized:
+		return iris.StatusUnauthorized
+	case apmapi.ErrCodeNotFound:
+		return iris.StatusNotFound
+	case apmapi.ErrCodeIncomplete:
+		return iris.StatusConflict
+	case apmapi.ErrCodeUpstreamUnavailable, apmapi.ErrCodeMalformedResponse:
+		return iris.StatusBadGateway
+	case apmapi.ErrCodeTimeout:
+		return iris.StatusGatewayTimeout
+	default:
+		return iris.StatusInternalServerError
+	}
+}
+
 type TraceListRequest struct {
 	ApmType    string `json:"apmType"`
 	TraceId    string `json:"traceId"`
 	StartTime  int64  `json:"startTime"`
 	Attributes string `json:"attributes"`
+	// Strict overrides the strict config of the backend, an incomplete trace is then returned as TRACE_INCOMPLETE.
+	Strict *bool `json:"strict,omitempty"`
 }
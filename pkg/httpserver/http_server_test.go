@@ -0,0 +1,125 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/global"
+	"github.com/kataras/iris/v12"
+)
+
+func TestRecoverConversion(t *testing.T) {
+	app := iris.New()
+	app.Use(recoverConversion)
+	app.Post("/trace/list", func(ctx iris.Context) {
+		ctx.Values().Set(contextTraceId, "abc")
+		ctx.Values().Set(contextApmType, "pinpoint")
+		var spans map[string]string
+		spans["root"] = "nil map"
+	})
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/trace/list", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("[Check status] want=%d, got=%d", http.StatusInternalServerError, recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, expect := range []string{`"errorCode":"INTERNAL"`, "traceId: abc"} {
+		if !strings.Contains(body, expect) {
+			t.Errorf("[Check body] want=%s, got=%s", expect, body)
+		}
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	jaegerServer := apmtest.NewJaegerServer()
+	defer jaegerServer.Close()
+	newClient := func(apmList ...string) *apmtrace.ApmTraceClient {
+		client, err := apmtrace.NewApmTraceClient(&config.TraceApiConfig{
+			ApmList:  apmList,
+			Backends: map[string]any{"jaeger": map[string]any{"address": jaegerServer.Address()}},
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	previous := global.TRACE_CLIENT.Load()
+	defer global.TRACE_CLIENT.Store(previous)
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("healthz", func(t *testing.T) {
+		global.TRACE_CLIENT.Store(nil)
+		recorder := serveGet(t, app, "/healthz", http.StatusOK)
+		if body := recorder.Body.String(); !strings.Contains(body, `"status":"ok"`) {
+			t.Errorf("[Check body] want status ok, got=%s", body)
+		}
+	})
+	t.Run("readyzNoClient", func(t *testing.T) {
+		global.TRACE_CLIENT.Store(nil)
+		serveGet(t, app, "/readyz", http.StatusServiceUnavailable)
+	})
+	t.Run("readyz", func(t *testing.T) {
+		global.TRACE_CLIENT.Store(newClient("jaeger"))
+		report := readReport(t, serveGet(t, app, "/readyz", http.StatusOK))
+		if !report.Ready || len(report.Backends) != 1 || report.Backends[0].ApmType != jaeger.ApmType || report.Backends[0].Status != apmtrace.HEALTH_OK {
+			t.Errorf("[Check report] want jaeger ok, got=%+v", report)
+		}
+	})
+	t.Run("readyzUnauthorized", func(t *testing.T) {
+		global.TRACE_CLIENT.Store(newClient("jaeger"))
+		jaegerServer.SetBasicAuth("apmtest", "secret")
+		defer jaegerServer.SetBasicAuth("", "")
+		report := readReport(t, serveGet(t, app, "/readyz", http.StatusServiceUnavailable))
+		if report.Ready || report.Backends[0].Status != apmtrace.HEALTH_FAIL {
+			t.Errorf("[Check report] want jaeger fail, got=%+v", report)
+		}
+	})
+	t.Run("readyzBuildFailure", func(t *testing.T) {
+		// skywalking is listed in apm_list without its section, the client is built with jaeger only.
+		global.TRACE_CLIENT.Store(newClient("jaeger", "skywalking"))
+		report := readReport(t, serveGet(t, app, "/readyz", http.StatusServiceUnavailable))
+		statuses := make(map[string]string)
+		for _, backend := range report.Backends {
+			statuses[backend.ApmType] = backend.Status
+		}
+		if report.Ready || statuses[jaeger.ApmType] != apmtrace.HEALTH_OK || statuses[skywalking.ApmType] != apmtrace.HEALTH_FAIL {
+			t.Errorf("[Check report] want skywalking fail and jaeger ok, got=%v", statuses)
+		}
+	})
+}
+
+func serveGet(t *testing.T, app *iris.Application, path string, expectStatus int) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != expectStatus {
+		t.Fatalf("[Check status] want=%d, got=%d, body=%s", expectStatus, recorder.Code, recorder.Body.String())
+	}
+	return recorder
+}
+
+func readReport(t *testing.T, recorder *httptest.ResponseRecorder) *apmtrace.HealthReport {
+	t.Helper()
+	report := &apmtrace.HealthReport{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), report); err != nil {
+		t.Fatalf("[Check body] %v, body=%s", err, recorder.Body.String())
+	}
+	return report
+}
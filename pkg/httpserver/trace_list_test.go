@@ -0,0 +1,234 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/apmtest"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/golden"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/jaeger"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/pinpoint"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/apmtrace/apmapi/skywalking"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/config"
+	"github.com/CloudDetail/apo-apm-adapter/pkg/global"
+	"github.com/CloudDetail/apo-module/apm/model/v1"
+	"github.com/kataras/iris/v12"
+)
+
+const testdataDir = "../apmtrace/apmapi/testdata/tracelist/"
+
+type traceListResponse struct {
+	Success    bool                     `json:"success"`
+	Data       []*model.OtelServiceNode `json:"data"`
+	Incomplete bool                     `json:"incomplete"`
+	ErrorCode  apmapi.ErrorCode         `json:"errorCode"`
+	ErrorMsg   string                   `json:"errorMsg"`
+}
+
+func TestQueryTraceList(t *testing.T) {
+	swServer := apmtest.NewSkywalkingServer()
+	defer swServer.Close()
+	swTraceId, err := swServer.AddFixture(testdataDir + "skywalking/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jaegerServer := apmtest.NewJaegerServer()
+	defer jaegerServer.Close()
+	jaegerTraceId, err := jaegerServer.AddFixture(testdataDir + "jaeger/http/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ppServer := apmtest.NewPinpointServer()
+	defer ppServer.Close()
+	ppTraceId, err := ppServer.AddFixture(testdataDir + "pinpoint/incomplete/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traceClient, err := apmtrace.NewApmTraceClient(&config.TraceApiConfig{
+		ApmList: []string{"skywalking", "jaeger", "pinpoint"},
+		Backends: map[string]any{
+			"skywalking": map[string]any{"address": swServer.Address()},
+			"jaeger":     map[string]any{"address": jaegerServer.Address()},
+			"pinpoint":   map[string]any{"address": ppServer.Address()},
+		},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := global.TRACE_CLIENT.Swap(traceClient)
+	defer global.TRACE_CLIENT.Store(previous)
+
+	app := iris.New()
+	registerRoutes(app)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("skywalking", func(t *testing.T) {
+		resp := postTraceList(t, app, http.StatusOK, skywalking.ApmType, swTraceId)
+		checkServices(t, resp, testdataDir+"skywalking/http/"+golden.ValidateFile)
+	})
+	t.Run("jaeger", func(t *testing.T) {
+		resp := postTraceList(t, app, http.StatusOK, jaeger.ApmType, jaegerTraceId)
+		checkServices(t, resp, testdataDir+"jaeger/http/"+golden.ValidateFile)
+	})
+	t.Run("incomplete", func(t *testing.T) {
+		resp := postTraceList(t, app, http.StatusOK, pinpoint.ApmType, ppTraceId)
+		checkServices(t, resp, testdataDir+"pinpoint/incomplete/"+golden.ValidateFile)
+		if !resp.Incomplete {
+			t.Errorf("[Check incomplete] want=true, got=false")
+		}
+	})
+	t.Run("strict", func(t *testing.T) {
+		strict := true
+		resp := postTraceListRequest(t, app, http.StatusConflict, &TraceListRequest{ApmType: pinpoint.ApmType, TraceId: ppTraceId, Strict: &strict})
+		checkErrorCode(t, resp, apmapi.ErrCodeIncomplete)
+	})
+	t.Run("recordIncomplete", func(t *testing.T) {
+		recordDir := t.TempDir()
+		previousDir := global.RECORD_DIR
+		global.RECORD_DIR = recordDir
+		defer func() { global.RECORD_DIR = previousDir }()
+
+		resp := postRecordRequest(t, app, http.StatusOK, &TraceListRequest{ApmType: pinpoint.ApmType, TraceId: ppTraceId}, "partial")
+		checkServices(t, resp, testdataDir+"pinpoint/incomplete/"+golden.ValidateFile)
+		if !resp.Incomplete {
+			t.Errorf("[Check record incomplete] want=true, got=false")
+		}
+
+		strict := true
+		resp = postRecordRequest(t, app, http.StatusConflict, &TraceListRequest{ApmType: pinpoint.ApmType, TraceId: ppTraceId, Strict: &strict}, "strict")
+		checkErrorCode(t, resp, apmapi.ErrCodeIncomplete)
+		if _, err := os.Stat(filepath.Join(recordDir, pinpoint.BackendName, "strict")); !os.IsNotExist(err) {
+			t.Errorf("[Check record strict] want no fixture, got err=%v", err)
+		}
+	})
+	t.Run("notFound", func(t *testing.T) {
+		resp := postTraceList(t, app, http.StatusNotFound, jaeger.ApmType, "unknown-trace-id")
+		checkErrorCode(t, resp, apmapi.ErrCodeNotFound)
+	})
+	t.Run("unknownApmType", func(t *testing.T) {
+		resp := postTraceList(t, app, http.StatusBadRequest, "zipkin", jaegerTraceId)
+		checkErrorCode(t, resp, apmapi.ErrCodeBadRequest)
+	})
+	t.Run("upstreamError", func(t *testing.T) {
+		swServer.SetFault(apmtest.Fault{Status: http.StatusInternalServerError})
+		defer swServer.SetFault(apmtest.Fault{})
+		resp := postTraceList(t, app, http.StatusBadGateway, skywalking.ApmType, swTraceId)
+		checkErrorCode(t, resp, apmapi.ErrCodeUpstreamUnavailable)
+	})
+	t.Run("malformedPayload", func(t *testing.T) {
+		// The callStack has no root span, the conversion fails after the response is decoded.
+		ppServer.AddTrace("broken^1718104578621^1", []byte(`{"transactionId":"broken^1718104578621^1","completeState":"Complete","callStack":[]}`))
+		resp := postTraceList(t, app, http.StatusBadGateway, pinpoint.ApmType, "broken^1718104578621^1")
+		checkErrorCode(t, resp, apmapi.ErrCodeMalformedResponse)
+
+		jaegerServer.SetFault(apmtest.Fault{Body: []byte(`{"data":[{"traceID":`)})
+		defer jaegerServer.SetFault(apmtest.Fault{})
+		resp = postTraceList(t, app, http.StatusBadGateway, jaeger.ApmType, jaegerTraceId)
+		checkErrorCode(t, resp, apmapi.ErrCodeMalformedResponse)
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		swServer.SetBasicAuth("apmtest", "secret")
+		defer swServer.SetBasicAuth("", "")
+		resp := postTraceList(t, app, http.StatusUnauthorized, skywalking.ApmType, swTraceId)
+		checkErrorCode(t, resp, apmapi.ErrCodeUnauthorized)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		jaegerServer.SetFault(apmtest.Fault{Latency: 1500 * time.Millisecond})
+		defer jaegerServer.SetFault(apmtest.Fault{})
+		resp := postTraceList(t, app, http.StatusGatewayTimeout, jaeger.ApmType, jaegerTraceId)
+		checkErrorCode(t, resp, apmapi.ErrCodeTimeout)
+	})
+	t.Run("badRequest", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/trace/list", strings.NewReader(`{"traceId":`)))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("[Check status] want=%d, got=%d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+	t.Run("readyz", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("[Check status] want=%d, got=%d, body=%s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+	})
+}
+
+func postTraceList(t *testing.T, app *iris.Application, expectStatus int, apmType string, traceId string) *traceListResponse {
+	t.Helper()
+	return postTraceListRequest(t, app, expectStatus, &TraceListRequest{
+		ApmType: apmType,
+		TraceId: traceId,
+	})
+}
+
+func postTraceListRequest(t *testing.T, app *iris.Application, expectStatus int, traceListRequest *TraceListRequest) *traceListResponse {
+	t.Helper()
+	return serveTraceList(t, app, expectStatus, newTraceListRequest(t, traceListRequest))
+}
+
+// postRecordRequest posts the request with the X-Apo-Record header, the case is recorded under global.RECORD_DIR.
+func postRecordRequest(t *testing.T, app *iris.Application, expectStatus int, traceListRequest *TraceListRequest, recordCase string) *traceListResponse {
+	t.Helper()
+	request := newTraceListRequest(t, traceListRequest)
+	request.Header.Set(HeaderRecord, recordCase)
+	return serveTraceList(t, app, expectStatus, request)
+}
+
+func newTraceListRequest(t *testing.T, traceListRequest *TraceListRequest) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(traceListRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/trace/list", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+func serveTraceList(t *testing.T, app *iris.Application, expectStatus int, request *http.Request) *traceListResponse {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, request)
+	if recorder.Code != expectStatus {
+		t.Fatalf("[Check status] want=%d, got=%d, body=%s", expectStatus, recorder.Code, recorder.Body.String())
+	}
+	resp := &traceListResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), resp); err != nil {
+		t.Fatalf("[Check body] %v, body=%s", err, recorder.Body.String())
+	}
+	return resp
+}
+
+func checkServices(t *testing.T, resp *traceListResponse, goldenFile string) {
+	t.Helper()
+	expect, err := golden.ReadTraceCase(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success {
+		t.Fatalf("[Check success] want=true, got=false, error=%s", resp.ErrorMsg)
+	}
+	if diffs := golden.DiffServices(expect.Services, resp.Data); len(diffs) > 0 {
+		t.Errorf("[Check %s] service tree mismatch:\n%s", goldenFile, strings.Join(diffs, "\n"))
+	}
+}
+
+func checkErrorCode(t *testing.T, resp *traceListResponse, expect apmapi.ErrorCode) {
+	t.Helper()
+	if resp.Success || resp.ErrorCode != expect {
+		t.Errorf("[Check ErrorCode] want=%s, got=%s (%s)", expect, resp.ErrorCode, resp.ErrorMsg)
+	}
+}